@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"go-on-oci-shared/httpserver"
+	"go-on-oci-shared/oracledb"
+	"go-on-oci-shared/settings"
+)
+
+// Config is the complete configuration of the data-service
+type Config struct {
+	HTTPServerPort       string                      `json:"httpServerPort"`
+	Version              string                      `json:"version"`
+	ShutdownGracePeriod  settings.Duration           `json:"shutdownGracePeriod"`
+	HTTP                 httpserver.MiddlewareConfig `json:"http"`
+	PeopleRepository     string                      `json:"peopleRepository"`
+	PeopleRepositoryFile string                      `json:"peopleRepositoryFile"`
+	Database             oracledb.Config             `json:"database"`
+}
+
+// configSettings are the environment variables and flags that override the configuration, with those of the shared packages
+var configSettings = slices.Concat(
+	[]settings.Setting[Config]{
+		{Flag: "http-port", EnvKey: ENV_KEY_HTTP_SERVER_PORT, Usage: "port the HTTP server listens on", Field: func(config *Config) interface{} { return &config.HTTPServerPort }},
+		{Flag: "version", EnvKey: ENV_KEY_MYSERVER_VERSION, Usage: "version reported by the server", Field: func(config *Config) interface{} { return &config.Version }},
+		{Flag: "shutdown-grace-period", EnvKey: ENV_KEY_SHUTDOWN_GRACE_PERIOD, Usage: "how long requests in flight get to complete at shutdown, such as 20s", Field: func(config *Config) interface{} { return &config.ShutdownGracePeriod }},
+	},
+	httpserver.MiddlewareSettings(func(config *Config) *httpserver.MiddlewareConfig { return &config.HTTP }),
+	[]settings.Setting[Config]{
+		{Flag: "people-repository", EnvKey: ENV_KEY_PEOPLE_REPOSITORY, Usage: "where persons are stored: oracle, memory or file", Field: func(config *Config) interface{} { return &config.PeopleRepository }},
+		{Flag: "people-repository-file", EnvKey: ENV_KEY_PEOPLE_REPOSITORY_FILE, Usage: "JSON file used by the file person repository", Field: func(config *Config) interface{} { return &config.PeopleRepositoryFile }},
+	},
+	oracledb.Settings(func(config *Config) *oracledb.Config { return &config.Database }),
+)
+
+func defaultConfig() Config {
+	return Config{
+		HTTPServerPort:       DEFAULT_HTTP_SERVER_PORT,
+		Version:              "unknown",
+		ShutdownGracePeriod:  settings.Duration(httpserver.DEFAULT_SHUTDOWN_GRACE_PERIOD),
+		PeopleRepository:     ORACLE_REPOSITORY,
+		PeopleRepositoryFile: DEFAULT_PEOPLE_REPOSITORY_FILE,
+		Database: oracledb.Config{
+			Driver: oracledb.GO_ORA_DRIVER,
+			Port:   oracledb.DEFAULT_DB_PORT,
+			Pool:   oracledb.PoolConfig{MaxOpenConns: 10, MaxIdleConns: 5, ConnMaxLifetime: settings.Duration(30 * time.Minute), ConnMaxIdleTime: settings.Duration(5 * time.Minute)},
+			Retry:  oracledb.RetryConfig{InitialBackoff: settings.Duration(oracledb.DEFAULT_INITIAL_BACKOFF), MaxBackoff: settings.Duration(oracledb.DEFAULT_MAX_BACKOFF), Deadline: settings.Duration(5 * time.Minute)},
+		},
+	}
+}
+
+// LoadConfig registers the configuration flags with the flag set, parses the arguments and builds the configuration in the
+// layers of settings.Load on top of the defaults. The configuration is not validated; call Validate before using it.
+func LoadConfig(flags *flag.FlagSet, arguments []string) (Config, error) {
+	config := defaultConfig()
+	err := settings.Load(flags, arguments, &config, configSettings)
+	return config, err
+}
+
+// Validate reports all problems with the configuration in a single error
+func (config Config) Validate() error {
+	var problems []string
+	if !oracledb.ValidPort(config.HTTPServerPort) {
+		problems = append(problems, fmt.Sprintf("httpServerPort %q is not a valid port", config.HTTPServerPort))
+	}
+	if config.ShutdownGracePeriod < 0 {
+		problems = append(problems, "shutdownGracePeriod can not be negative")
+	}
+	if config.HTTP.RateLimit < 0 || config.HTTP.RateLimitBurst < 0 || config.HTTP.TrustedProxies < 0 {
+		problems = append(problems, "http.rateLimit, http.rateLimitBurst and http.trustedProxies can not be negative")
+	}
+	switch config.PeopleRepository {
+	case ORACLE_REPOSITORY:
+		problems = append(problems, config.Database.Problems()...)
+	case MEMORY_REPOSITORY:
+	case FILE_REPOSITORY:
+		if config.PeopleRepositoryFile == "" {
+			problems = append(problems, "peopleRepositoryFile is required for the file person repository")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("peopleRepository %q is unknown; use %s, %s or %s", config.PeopleRepository, ORACLE_REPOSITORY, MEMORY_REPOSITORY, FILE_REPOSITORY))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// PrintConfig writes the configuration as JSON, with secrets redacted
+func PrintConfig(config Config, out io.Writer) error {
+	return settings.Print(config, configSettings, out)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-on-oci-shared/oracledb"
+	"go-on-oci-shared/settings"
+)
+
+func TestLoadConfigLayers(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	content := `{"httpServerPort":"9090","peopleRepository":"memory","database":{"server":"file-host","username":"file-user","password":"secret"}}`
+	if err := ioutil.WriteFile(configFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(oracledb.ENV_KEY_DB_SERVER, "env-host")
+	os.Setenv(oracledb.ENV_KEY_DB_USERNAME, "env-user")
+	defer os.Unsetenv(oracledb.ENV_KEY_DB_SERVER)
+	defer os.Unsetenv(oracledb.ENV_KEY_DB_USERNAME)
+
+	config, err := LoadConfig(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", configFile, "-db-username", "flag-user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct{ name, expected, result string }{
+		{"default", oracledb.DEFAULT_DB_PORT, config.Database.Port},
+		{"config file", "9090", config.HTTPServerPort},
+		{"environment over config file", "env-host", config.Database.Server},
+		{"flag over environment", "flag-user", config.Database.Username},
+	}
+	for _, c := range cases {
+		if c.result != c.expected {
+			t.Fatalf("%s: want %s, got %s\n", c.name, c.expected, c.result)
+		}
+	}
+
+	var out bytes.Buffer
+	PrintConfig(config, &out)
+	if strings.Contains(out.String(), "secret") || !strings.Contains(out.String(), settings.REDACTED) {
+		t.Fatalf("password is not redacted in %s\n", out.String())
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	config := defaultConfig()
+	config.HTTPServerPort = "eighty"
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("invalid configuration passed validation")
+	}
+	for _, problem := range []string{"httpServerPort", "database.service", "database.password"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Fatalf("want a problem with %s, got %s\n", problem, err)
+		}
+	}
+	config.HTTPServerPort = DEFAULT_HTTP_SERVER_PORT
+	config.PeopleRepository = MEMORY_REPOSITORY
+	if err := config.Validate(); err != nil {
+		t.Fatalf("valid configuration failed validation: %s\n", err)
+	}
+}
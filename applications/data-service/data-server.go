@@ -3,161 +3,231 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"log"
-	"net/http"
 	"time"
-)
 
-var autonomousDB = map[string]string{
-	"service":        "k8j2fvxbaujdcfy_goonocidb_medium.adb.oraclecloud.com",
-	"username":       "demo",
-	"server":         "adb.us-ashburn-1.oraclecloud.com",
-	"port":           "1522",
-	"password":       "thePassword1",
-	"walletLocation": ".",
-}
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/oracledb"
+	"go-on-oci-shared/people"
+	"go-on-oci-shared/schema"
+)
 
+// Person is a record in the PEOPLE table; CreatedAt and UpdatedAt are maintained by the database and ignored on input
 type Person struct {
-	Name         string `json:"name"`
-	Age          int    `json:"age"`
-	JuicyDetails string `json:"comment"`
+	Name         string     `json:"name"`
+	Age          int        `json:"age"`
+	JuicyDetails string     `json:"comment"`
+	CreatedAt    *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt    *time.Time `json:"updatedAt,omitempty"`
+	Version      int        `json:"-"`
+	// ageUnknown is set for a person read with a NULL age, which sorts as -1 rather than as the 0 in Age
+	ageUnknown bool
 }
 
 const (
 	PEOPLE_TABLE_NAME = "PEOPLE"
 )
 
-var database *sql.DB
+// oracleRepository is the PersonRepository that keeps persons in the PEOPLE table in an Oracle Database;
+// while the connection is being established, every call fails with oracledb.ErrDatabaseUnavailable
+type oracleRepository struct {
+	bootstrap *oracledb.Bootstrap
+}
 
-func tableExists(db *sql.DB, tableName string) (bool, error) {
-	var tblCount int32
-	err := db.QueryRow("SELECT count(table_name) tbl_count FROM user_tables where table_name = upper(:tablename)", tableName).Scan(&tblCount)
-	exists := tblCount > 0
-	return exists, err
+// NewOracleRepository returns the repository for persons in the database that the bootstrap connects to
+func NewOracleRepository(bootstrap *oracledb.Bootstrap) PersonRepository {
+	return &oracleRepository{bootstrap: bootstrap}
 }
 
+// InitializeDataServer brings the schema up to date by applying all pending schema migrations
 func InitializeDataServer(db *sql.DB) error {
-	database = db
-	exists, err := tableExists(db, PEOPLE_TABLE_NAME)
-	if err != nil {
-		return err
+	return schema.MigrateUp(db)
+}
+
+func (repository *oracleRepository) database() (*sql.DB, error) {
+	return repository.bootstrap.Database()
+}
+
+func (repository *oracleRepository) GetPerson(ctx context.Context, name string) (Person, error) {
+	person, err := repository.retrievePerson(ctx, name)
+	if err == sql.ErrNoRows {
+		return person, ErrPersonNotFound
 	}
-	if !exists {
-		_, err = db.Exec(createTableStatement)
-		log.Printf("Created table %s in Oracle Database \n", PEOPLE_TABLE_NAME)
-		if err != nil {
-			return err
-		}
+	return person, err
+}
+
+func (repository *oracleRepository) SavePerson(ctx context.Context, person Person, condition WriteCondition) (Person, error) {
+	err := repository.persistPerson(ctx, person, condition)
+	if err != nil {
+		return person, err
 	}
-	return nil
+	// read the person back, to include the timestamps and version set by the database
+	return repository.retrievePerson(ctx, person.Name)
 }
 
-func DataHandler(response http.ResponseWriter, request *http.Request) {
-	log.Printf("Handle Request for method %s on path %s", request.Method, request.URL.Path)
-	if request.Method == "GET" {
-		queryNameParameter := request.URL.Query().Get("name")
-		selectStatement := fmt.Sprintf(
-			`select age, creation_time, description from %s where name = :name `,
-			PEOPLE_TABLE_NAME)
-		var creationTime time.Time
-		var person Person
-		person.Name = queryNameParameter
-		row := database.QueryRow(selectStatement, person.Name)
-		err := row.Scan(&person.Age, &creationTime, &person.JuicyDetails)
-		if err != nil {
-			http.Error(response, err.Error(), http.StatusBadRequest)
-			return
-		}
-		personJson, _ := json.Marshal(person)
-		fmt.Fprint(response, string(personJson))
-	}
-	if request.Method == "PUT" || request.Method == "POST" {
-		var person Person
-		// Try to decode the request body into the struct. If there is an error,
-		// respond to the client with the error message and a 400 status code.
-		err := json.NewDecoder(request.Body).Decode(&person)
-		if err != nil {
-			http.Error(response, err.Error(), http.StatusBadRequest)
-			return
-		}
-		persistPerson(person)
-		fmt.Fprint(response, fmt.Sprintf("Persisted %s!", person.Name))
-	}
-	if request.Method == "DELETE" {
-		var person Person
-		// Try to decode the request body into the struct. If there is an error,
-		// respond to the client with the error message and a 400 status code.
-		err := json.NewDecoder(request.Body).Decode(&person)
-		if err != nil {
-			http.Error(response, err.Error(), http.StatusBadRequest)
-			return
-		}
-		unpersistPerson(person.Name)
-		fmt.Fprint(response, fmt.Sprintf("Removed record for %s!", person.Name))
+func (repository *oracleRepository) DeletePerson(ctx context.Context, name string, condition WriteCondition) error {
+	return repository.unpersistPerson(ctx, name, condition)
+}
+
+func (repository *oracleRepository) retrievePerson(ctx context.Context, name string) (Person, error) {
+	selectStatement := fmt.Sprintf(
+		`select age, creation_time, updated_time, description, row_version from %s where name = :name `,
+		PEOPLE_TABLE_NAME)
+	var creationTime, updatedTime sql.NullTime
+	var description sql.NullString
+	var person Person
+	person.Name = name
+	database, err := repository.database()
+	if err != nil {
+		return person, err
 	}
+	done := oracledb.TraceStatement(ctx, PEOPLE_TABLE_NAME, "retrievePerson", "select")
+	row := database.QueryRowContext(ctx, selectStatement, name)
+	err = row.Scan(&person.Age, &creationTime, &updatedTime, &description, &person.Version)
+	done(err)
+	person.JuicyDetails = description.String
+	person.CreatedAt = nullableTime(creationTime)
+	person.UpdatedAt = nullableTime(updatedTime)
+	return person, err
+}
 
+func nullableTime(timestamp sql.NullTime) *time.Time {
+	if !timestamp.Valid {
+		return nil
+	}
+	return &timestamp.Time
 }
 
-func persistPerson(person Person) {
-	ctx := context.Background()
+// persistPerson writes a person to the database, taking the condition into account: a person that must not exist is inserted,
+// a person with an expected version is only updated when it still has that version and otherwise the person is merged
+func (repository *oracleRepository) persistPerson(ctx context.Context, person Person, condition WriteCondition) error {
+	database, err := repository.database()
+	if err != nil {
+		return err
+	}
 	tx, err := database.BeginTx(ctx, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	switch {
+	case condition.MustNotExist:
+		err = insertPerson(ctx, tx, person)
+		if err != nil && oracledb.ErrorCode(err) == 1 {
+			err = ErrPreconditionFailed
+		}
+	case condition.Version > 0:
+		err = updatePersonVersion(ctx, tx, person, condition.Version)
+	default:
+		err = mergePerson(ctx, tx, person)
 	}
-	err = mergePerson(ctx, tx, person)
 	if err != nil {
-		log.Fatal(err)
+		rollback(tx)
+		return err
 	}
 	err = tx.Commit()
 	if err != nil {
-		log.Fatal(err)
-	} else {
-		log.Printf("Merged record in table %s for person %s", PEOPLE_TABLE_NAME, person.Name)
+		return err
 	}
+	logging.FromContext(ctx).Info("merged record", "table", PEOPLE_TABLE_NAME, "person", person.Name)
+	return nil
 }
 
-func unpersistPerson(name string) {
-	ctx := context.Background()
+// unpersistPerson removes a person from the database; with an expected version in the condition, only when the person still has that version
+func (repository *oracleRepository) unpersistPerson(ctx context.Context, name string, condition WriteCondition) error {
+	database, err := repository.database()
+	if err != nil {
+		return err
+	}
 	tx, err := database.BeginTx(ctx, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	err = deletePerson(ctx, tx, name)
+	err = deletePerson(ctx, tx, name, condition.Version)
 	if err != nil {
-		log.Fatal(err)
+		rollback(tx)
+		return err
 	}
 	err = tx.Commit()
 	if err != nil {
-		log.Fatal(err)
-	} else {
-		log.Printf("Delete record from table %s for person %s", PEOPLE_TABLE_NAME, name)
+		return err
 	}
+	logging.FromContext(ctx).Info("deleted record", "table", PEOPLE_TABLE_NAME, "person", name)
+	return nil
 }
 
-const createTableStatement = "CREATE TABLE PEOPLE ( NAME VARCHAR2(100), AGE NUMBER(3), DESCRIPTION VARCHAR2(1000), CREATION_TIME TIMESTAMP DEFAULT SYSTIMESTAMP)"
-const dropTableStatement = "DROP TABLE PEOPLE PURGE"
+func rollback(tx *sql.Tx) {
+	err := tx.Rollback()
+	if err != nil {
+		logging.Error("failed to roll back transaction", "error", err)
+	}
+}
 
 // const insertStatement = "INSERT INTO PEOPLE ( NAME , AGE, DESCRIPTION) VALUES (:name, :age, :description)"
 // const mergeStatement = "INSERT INTO PEOPLE ( NAME , AGE, DESCRIPTION) VALUES (:name, :age, :description)"
 // const queryStatement = "SELECT name, age, description, creation_time, value FROM PEOPLE"
 
 func mergePerson(ctx context.Context, tx *sql.Tx, person Person) error {
-	mergeStatement := fmt.Sprintf(
-		`MERGE INTO %s t using (select :name name, :age age, :description description from dual) person
-		ON (t.name = person.name )
-		WHEN MATCHED THEN UPDATE SET age = person.age, description = person.description
-		WHEN NOT MATCHED THEN INSERT (t.name, t.age, t.description) values (person.name, person.age, person.description) `,
+	done := oracledb.TraceStatement(ctx, PEOPLE_TABLE_NAME, "mergePerson", "merge")
+	_, err := tx.ExecContext(ctx, people.MERGE_STATEMENT, person.Name, person.Age, person.JuicyDetails)
+	done(err)
+	return err
+}
+
+func insertPerson(ctx context.Context, tx *sql.Tx, person Person) error {
+	insertStatement := fmt.Sprintf(
+		`insert into %s (name, age, description, updated_time) values (:name, :age, :description, systimestamp) `,
 		PEOPLE_TABLE_NAME)
-	_, err := tx.ExecContext(ctx, mergeStatement, person.Name, person.Age, person.JuicyDetails)
+	done := oracledb.TraceStatement(ctx, PEOPLE_TABLE_NAME, "insertPerson", "insert")
+	_, err := tx.ExecContext(ctx, insertStatement, person.Name, person.Age, person.JuicyDetails)
+	done(err)
 	return err
 }
 
-func deletePerson(ctx context.Context, tx *sql.Tx, name string) error {
+func updatePersonVersion(ctx context.Context, tx *sql.Tx, person Person, version int) error {
+	updateStatement := fmt.Sprintf(
+		`update %s set age = :age, description = :description, updated_time = systimestamp, row_version = row_version + 1
+		where name = :name and row_version = :version `,
+		PEOPLE_TABLE_NAME)
+	done := oracledb.TraceStatement(ctx, PEOPLE_TABLE_NAME, "updatePersonVersion", "update")
+	result, err := tx.ExecContext(ctx, updateStatement, person.Age, person.JuicyDetails, person.Name, version)
+	done(err)
+	return expectOneRow(result, err)
+}
+
+// deletePerson removes the person with the given name; when version is not 0, only if the person still has that row version
+func deletePerson(ctx context.Context, tx *sql.Tx, name string, version int) error {
+	if version == 0 {
+		deleteStatement := fmt.Sprintf(
+			`delete %s where name = :name `,
+			PEOPLE_TABLE_NAME)
+		done := oracledb.TraceStatement(ctx, PEOPLE_TABLE_NAME, "deletePerson", "delete")
+		result, err := tx.ExecContext(ctx, deleteStatement, name)
+		done(err)
+		if err = expectOneRow(result, err); err == ErrPreconditionFailed {
+			return ErrPersonNotFound
+		}
+		return err
+	}
 	deleteStatement := fmt.Sprintf(
-		`delete %s where name = :name `,
+		`delete %s where name = :name and row_version = :version `,
 		PEOPLE_TABLE_NAME)
-	_, err := tx.ExecContext(ctx, deleteStatement, name)
-	return err
+	done := oracledb.TraceStatement(ctx, PEOPLE_TABLE_NAME, "deletePerson", "delete")
+	result, err := tx.ExecContext(ctx, deleteStatement, name, version)
+	done(err)
+	return expectOneRow(result, err)
+}
+
+// expectOneRow turns a conditional statement that did not touch any row into ErrPreconditionFailed
+func expectOneRow(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	rowCount, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowCount == 0 {
+		return ErrPreconditionFailed
+	}
+	return nil
 }
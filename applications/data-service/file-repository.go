@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// fileRepository is the PersonRepository that keeps persons in memory and writes all of them to a JSON file on every change,
+// so they survive a restart of the data-service without requiring a database. A change is only made in memory once the file
+// with it is written, so memory never holds what a restart would lose.
+type fileRepository struct {
+	*memoryRepository
+	path string
+}
+
+// storedPerson is the form in which a person is written to the file, including the version that is not part of the API representation
+type storedPerson struct {
+	Person
+	Version int `json:"version"`
+}
+
+func NewFileRepository(path string) (*fileRepository, error) {
+	repository := &fileRepository{memoryRepository: NewMemoryRepository(), path: path}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return repository, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var storedPeople []storedPerson
+	err = json.Unmarshal(content, &storedPeople)
+	if err != nil {
+		return nil, err
+	}
+	for _, stored := range storedPeople {
+		person := stored.Person
+		person.Version = stored.Version
+		repository.people[person.Name] = person
+	}
+	return repository, nil
+}
+
+func (repository *fileRepository) SavePerson(ctx context.Context, person Person, condition WriteCondition) (Person, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	person, err := repository.personToSave(person, condition)
+	if err != nil {
+		return person, err
+	}
+	if err := repository.writeFile(person, ""); err != nil {
+		return person, err
+	}
+	repository.people[person.Name] = person
+	return person, nil
+}
+
+func (repository *fileRepository) DeletePerson(ctx context.Context, name string, condition WriteCondition) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	if err := repository.checkDelete(name, condition); err != nil {
+		return err
+	}
+	if err := repository.writeFile(Person{}, name); err != nil {
+		return err
+	}
+	delete(repository.people, name)
+	return nil
+}
+
+// writeFile replaces the file with the persons in memory, with saved instead of the person of the same name (unless saved
+// has no name) and without the person named deleted; the caller holds the mutex. The content is written to a temporary file
+// first, so a crash halfway never leaves a truncated file behind.
+func (repository *fileRepository) writeFile(saved Person, deleted string) error {
+	storedPeople := make([]storedPerson, 0, len(repository.people)+1)
+	for name, person := range repository.people {
+		if name != saved.Name && name != deleted {
+			storedPeople = append(storedPeople, storedPerson{Person: person, Version: person.Version})
+		}
+	}
+	if saved.Name != "" {
+		storedPeople = append(storedPeople, storedPerson{Person: saved, Version: saved.Version})
+	}
+	sort.Slice(storedPeople, func(i, j int) bool { return storedPeople[i].Name < storedPeople[j].Name })
+	content, err := json.MarshalIndent(storedPeople, "", "  ")
+	if err != nil {
+		return err
+	}
+	temporaryFile, err := ioutil.TempFile(filepath.Dir(repository.path), filepath.Base(repository.path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = temporaryFile.Write(content)
+	if closeErr := temporaryFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temporaryFile.Name())
+		return err
+	}
+	return os.Rename(temporaryFile.Name(), repository.path)
+}
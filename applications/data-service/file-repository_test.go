@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileRepository(t *testing.T) {
+	directory, err := ioutil.TempDir("", "people")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "people.json")
+	ctx := context.Background()
+	fileRepository, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Mary", "John"} {
+		if _, err := fileRepository.SavePerson(ctx, Person{Name: name, Age: 42}, WriteCondition{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fileRepository.DeletePerson(ctx, "John", WriteCondition{}); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mary, err := reopened.GetPerson(ctx, "Mary"); err != nil || mary.Version != 1 || mary.CreatedAt == nil {
+		t.Fatalf("want Mary read back with version and timestamps, got %+v (%v)\n", mary, err)
+	}
+	if _, err := reopened.GetPerson(ctx, "John"); err != ErrPersonNotFound {
+		t.Fatalf("want John deleted from the file, got %v\n", err)
+	}
+
+	// once the file cannot be written, a change must fail without showing up in memory
+	os.RemoveAll(directory)
+	if _, err := fileRepository.SavePerson(ctx, Person{Name: "Anna", Age: 7}, WriteCondition{}); err == nil {
+		t.Fatal("want saving to fail when the file cannot be written")
+	}
+	if _, err := fileRepository.GetPerson(ctx, "Anna"); err != ErrPersonNotFound {
+		t.Fatalf("want Anna not saved in memory, got %v\n", err)
+	}
+	if _, err := fileRepository.SavePerson(ctx, Person{Name: "Mary", Age: 43}, WriteCondition{}); err == nil {
+		t.Fatal("want updating to fail when the file cannot be written")
+	}
+	if err := fileRepository.DeletePerson(ctx, "Mary", WriteCondition{}); err == nil {
+		t.Fatal("want deleting to fail when the file cannot be written")
+	}
+	if mary, err := fileRepository.GetPerson(ctx, "Mary"); err != nil || mary.Age != 42 || mary.Version != 1 {
+		t.Fatalf("want Mary unchanged in memory, got %+v (%v)\n", mary, err)
+	}
+}
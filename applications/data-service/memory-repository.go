@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryRepository is the PersonRepository that keeps persons in memory only; handy for local development and tests
+type memoryRepository struct {
+	mutex  sync.RWMutex
+	people map[string]Person
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{people: map[string]Person{}}
+}
+
+func (repository *memoryRepository) GetPerson(ctx context.Context, name string) (Person, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	person, ok := repository.people[name]
+	if !ok {
+		return Person{Name: name}, ErrPersonNotFound
+	}
+	return person, nil
+}
+
+func (repository *memoryRepository) SavePerson(ctx context.Context, person Person, condition WriteCondition) (Person, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	person, err := repository.personToSave(person, condition)
+	if err != nil {
+		return person, err
+	}
+	repository.people[person.Name] = person
+	return person, nil
+}
+
+// personToSave returns the person as SavePerson stores it, with timestamps and version, without storing it yet;
+// the caller holds the mutex
+func (repository *memoryRepository) personToSave(person Person, condition WriteCondition) (Person, error) {
+	current, exists := repository.people[person.Name]
+	if (condition.MustNotExist && exists) || (condition.Version > 0 && (!exists || current.Version != condition.Version)) {
+		return person, ErrPreconditionFailed
+	}
+	now := time.Now()
+	person.CreatedAt = &now
+	person.Version = 1
+	if exists {
+		person.CreatedAt = current.CreatedAt
+		person.Version = current.Version + 1
+	}
+	person.UpdatedAt = &now
+	return person, nil
+}
+
+func (repository *memoryRepository) DeletePerson(ctx context.Context, name string, condition WriteCondition) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	if err := repository.checkDelete(name, condition); err != nil {
+		return err
+	}
+	delete(repository.people, name)
+	return nil
+}
+
+// checkDelete returns the error DeletePerson returns for the person, or nil when it can be deleted; the caller holds the mutex
+func (repository *memoryRepository) checkDelete(name string, condition WriteCondition) error {
+	current, exists := repository.people[name]
+	if condition.Version > 0 && (!exists || current.Version != condition.Version) {
+		return ErrPreconditionFailed
+	}
+	if !exists {
+		return ErrPersonNotFound
+	}
+	return nil
+}
+
+// ListPeople applies the filters, sort order and pagination of the query the same way the Oracle repository does in SQL
+func (repository *memoryRepository) ListPeople(ctx context.Context, query PeopleQuery) (PeoplePage, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	page := PeoplePage{Items: []Person{}, Limit: query.Limit, Offset: query.Offset}
+	var cursorKey interface{}
+	if query.Cursor != nil {
+		var err error
+		cursorKey, err = query.Cursor.bindValue()
+		if err != nil {
+			return page, err
+		}
+	}
+	matches := []Person{}
+	for _, person := range repository.people {
+		if !query.matches(person) {
+			continue
+		}
+		page.Total++
+		if query.Cursor != nil && query.compare(query.sortKey(person), person.Name, cursorKey, query.Cursor.Name) <= 0 {
+			continue
+		}
+		matches = append(matches, person)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return query.compare(query.sortKey(matches[i]), matches[i].Name, query.sortKey(matches[j]), matches[j].Name) < 0
+	})
+	if query.Offset < len(matches) {
+		matches = matches[query.Offset:]
+	} else {
+		matches = nil
+	}
+	if len(matches) > query.Limit {
+		page.Items = append(page.Items, matches[:query.Limit]...)
+		page.NextCursor = query.nextCursor(page.Items[len(page.Items)-1])
+	} else {
+		page.Items = append(page.Items, matches...)
+	}
+	return page, nil
+}
+
+func (query PeopleQuery) matches(person Person) bool {
+	// like the SQL comparisons, an age filter leaves out persons without an age
+	if (query.MinAge != nil || query.MaxAge != nil) && person.ageUnknown {
+		return false
+	}
+	if query.MinAge != nil && person.Age < *query.MinAge {
+		return false
+	}
+	if query.MaxAge != nil && person.Age > *query.MaxAge {
+		return false
+	}
+	if query.NamePrefix != "" && !strings.HasPrefix(person.Name, query.NamePrefix) {
+		return false
+	}
+	if query.CreatedAfter != nil && (person.CreatedAt == nil || person.CreatedAt.Before(*query.CreatedAfter)) {
+		return false
+	}
+	if query.CreatedBefore != nil && (person.CreatedAt == nil || !person.CreatedAt.Before(*query.CreatedBefore)) {
+		return false
+	}
+	return true
+}
+
+// compare orders two persons, given by their sort key and name, in the direction of the query; the name breaks ties
+func (query PeopleQuery) compare(key interface{}, name string, otherKey interface{}, otherName string) int {
+	result := compareSortKeys(key, otherKey)
+	if result == 0 {
+		result = strings.Compare(name, otherName)
+	}
+	if query.Descending {
+		return -result
+	}
+	return result
+}
+
+func compareSortKeys(key interface{}, otherKey interface{}) int {
+	switch value := key.(type) {
+	case int:
+		otherValue, _ := otherKey.(int)
+		return value - otherValue
+	case time.Time:
+		otherValue, _ := otherKey.(time.Time)
+		if value.Before(otherValue) {
+			return -1
+		}
+		if value.After(otherValue) {
+			return 1
+		}
+		return 0
+	default:
+		otherValue, _ := otherKey.(string)
+		return strings.Compare(key.(string), otherValue)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-on-oci-shared/httpserver"
+	"go-on-oci-shared/metrics"
+	"go-on-oci-shared/oracledb"
+)
+
+func TestMetricsMiddlewareLabelsRoutes(t *testing.T) {
+	router := httpserver.NewRouter()
+	router.HandleFunc("/metrics-test/", func(response http.ResponseWriter, request *http.Request) {
+		http.Error(response, "gone", http.StatusGone)
+	}, "BREW")
+	handler := httpserver.MetricsMiddleware(router)(router)
+	for _, path := range []string{"/metrics-test/Mary", "/metrics-test/John"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", path, nil))
+	}
+	oracledb.ObserveStatement("metrics-test", time.Now(), errors.New("ORA-03113: end-of-file on communication channel"))
+
+	response := httptest.NewRecorder()
+	metrics.Handler(metrics.Default())(response, httptest.NewRequest("GET", metrics.METRICS_PATH, nil))
+	if contentType := response.Header().Get("Content-Type"); contentType != metrics.PROMETHEUS_TEXT_CONTENT_TYPE {
+		t.Fatalf("want content type %s, got %s\n", metrics.PROMETHEUS_TEXT_CONTENT_TYPE, contentType)
+	}
+	for _, line := range []string{
+		`http_requests_total{route="/metrics-test/",method="OTHER",status="410"} 2`,
+		`http_request_duration_seconds_count{route="/metrics-test/",method="OTHER"} 2`,
+		`db_statement_errors_total{statement="metrics-test"} 1`,
+		`db_statement_duration_seconds_count{statement="metrics-test"} 1`,
+	} {
+		if !strings.Contains(response.Body.String(), line+"\n") {
+			t.Fatalf("want line %s in\n%s", line, response.Body.String())
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-on-oci-shared/httpserver"
+)
+
+func TestServerHandler(t *testing.T) {
+	router := httpserver.NewRouter()
+	router.HandleFunc(GREET_PATH, greetHandler, http.MethodGet)
+	router.HandleFunc(httpserver.HEALTHZ_PATH, httpserver.HealthHandler(nil), http.MethodGet)
+	router.HandleFunc("/panic", func(response http.ResponseWriter, request *http.Request) {
+		panic("handler is broken")
+	}, http.MethodGet)
+	handler := httpserver.ServerHandler(router, httpserver.MiddlewareConfig{
+		CORSAllowedOrigins: "https://example.com",
+		APITokens:          "token-1, token-2",
+		RateLimit:          1,
+		RateLimitBurst:     100,
+		PublicPaths:        []string{httpserver.HEALTHZ_PATH},
+	})
+	cases := []struct {
+		name, method, path string
+		headers            []string
+		status             int
+		header, value      string
+	}{
+		{"no token", "GET", GREET_PATH, nil, http.StatusUnauthorized, "WWW-Authenticate", `Bearer realm="go-on-oci"`},
+		{"wrong token", "GET", GREET_PATH, []string{"Authorization", "Bearer token-3"}, http.StatusUnauthorized, "", ""},
+		{"valid token", "GET", GREET_PATH, []string{"Authorization", "Bearer token-2"}, http.StatusOK, "", ""},
+		{"public path", "GET", httpserver.HEALTHZ_PATH, nil, http.StatusOK, "", ""},
+		{"wrong method", "DELETE", GREET_PATH, []string{"Authorization", "Bearer token-1"}, http.StatusMethodNotAllowed, "Allow", "GET, HEAD, OPTIONS"},
+		{"panic", "GET", "/panic", []string{"Authorization", "Bearer token-1"}, http.StatusInternalServerError, "", ""},
+		{"preflight", "OPTIONS", GREET_PATH, []string{"Origin", "https://example.com", "Access-Control-Request-Method", "GET"}, http.StatusNoContent, "Access-Control-Allow-Origin", "https://example.com"},
+		{"other origin", "GET", GREET_PATH, []string{"Origin", "https://example.org", "Authorization", "Bearer token-1"}, http.StatusOK, "Access-Control-Allow-Origin", ""},
+		{"compressed", "GET", GREET_PATH, []string{"Authorization", "Bearer token-1", "Accept-Encoding", "br, gzip"}, http.StatusOK, "Content-Encoding", "gzip"},
+	}
+
+	for _, c := range cases {
+		request := httptest.NewRequest(c.method, c.path, nil)
+		for i := 0; i+1 < len(c.headers); i += 2 {
+			request.Header.Set(c.headers[i], c.headers[i+1])
+		}
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		if response.Code != c.status {
+			t.Fatalf("%s: want status %d, got %d (%s)\n", c.name, c.status, response.Code, response.Body.String())
+		}
+		if c.header != "" && response.Header().Get(c.header) != c.value {
+			t.Fatalf("%s: want %s %q, got %q\n", c.name, c.header, c.value, response.Header().Get(c.header))
+		}
+		if response.Header().Get(httpserver.REQUEST_ID_HEADER) == "" {
+			t.Fatalf("%s: want a request ID\n", c.name)
+		}
+	}
+}
@@ -1,78 +1,141 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
+	"time"
+
+	"go-on-oci-shared/httpserver"
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/metrics"
+	"go-on-oci-shared/oracledb"
+	"go-on-oci-shared/schema"
+	"go-on-oci-shared/tracing"
 )
 
 const (
-	DEFAULT_HTTP_SERVER_PORT = "8080"
-	ENV_KEY_HTTP_SERVER_PORT = "HTTP_SERVER_PORT"
-	ENV_KEY_MYSERVER_VERSION = "VERSION_OF_MYSERVER"
+	DEFAULT_HTTP_SERVER_PORT      = "8080"
+	ENV_KEY_HTTP_SERVER_PORT      = "HTTP_SERVER_PORT"
+	ENV_KEY_MYSERVER_VERSION      = "VERSION_OF_MYSERVER"
+	ENV_KEY_SHUTDOWN_GRACE_PERIOD = "SHUTDOWN_GRACE_PERIOD"
 )
 
 const (
 	GREET_PATH       = "/greet"
 	STATIC_SITE_PATH = "/site/"
-	DATA_PATH        = "/data"
-	ROOT_PATH        = "/"
+	PEOPLE_PATH      = "/people"
+	PERSON_PATH      = "/people/"
 )
 
 func ComposeGreeting(name string) string {
 	nameToGreet := "Stranger"
 	if len(name) > 0 {
 		nameToGreet = name
-		log.Printf(" --  query parameter name is set to %s", name)
+		logging.Debug("query parameter name is set", "name", name)
 	}
 	return fmt.Sprintf("Hello %s!", nameToGreet)
 }
 
 func greetHandler(response http.ResponseWriter, request *http.Request) {
-	log.Printf("Handle Request for method %s on path %s", request.Method, request.URL.Path)
-	if request.Method != "GET" {
-		http.Error(response, "Method is not supported unfortunately. ", http.StatusNotFound)
-		return
-	}
 	queryName := request.URL.Query().Get("name")
 	fmt.Fprint(response, ComposeGreeting(queryName))
 }
 
 func fallbackHandler(response http.ResponseWriter, request *http.Request) {
-	log.Printf("Warning: Request for unhandled method %s on path %s", request.Method, request.URL.Path)
-	http.Error(response, "404 path/method combination not currently supported. Try /greet or /site", http.StatusNotFound)
+	logging.FromContext(request.Context()).Warn("request for unhandled path", "method", request.Method, "path", request.URL.Path)
+	http.Error(response, "404 path not currently supported. Try /greet, /people or /site", http.StatusNotFound)
+}
+
+// newRouter registers the routes of the data-service; bootstrap is nil unless persons are stored in the database
+func newRouter(bootstrap *oracledb.Bootstrap) *httpserver.Router {
+	router := httpserver.NewRouter()
+	fileServer := http.FileServer(http.Dir("./website"))
+	router.Handle(STATIC_SITE_PATH, http.StripPrefix("/site/", fileServer), http.MethodGet)
+	router.HandleFunc(GREET_PATH, greetHandler, http.MethodGet)
+	router.HandleFunc(PEOPLE_PATH, PeopleHandler, http.MethodGet, http.MethodPost)
+	// /people/ without a name is the collection as well, hence POST
+	router.HandleFunc(PERSON_PATH, PersonHandler, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete)
+	router.HandleFunc(httpserver.HEALTHZ_PATH, httpserver.HealthHandler(bootstrap), http.MethodGet)
+	router.HandleFunc(httpserver.READYZ_PATH, httpserver.ReadinessHandler(bootstrap), http.MethodGet)
+	router.HandleFunc(metrics.METRICS_PATH, metrics.Handler(metrics.Default()), http.MethodGet)
+	router.NotFound = http.HandlerFunc(fallbackHandler)
+	router.MethodNotAllowed = http.HandlerFunc(httpserver.MethodNotAllowedHandler)
+	return router
 }
 
 func main() {
-	db := GetSqlDBWithPureDriver(autonomousDB)
-	defer func() {
-		err := db.Close()
+	migrateCommand := flag.String("migrate", "", "run schema migrations and exit: up applies all pending migrations, down rolls back the latest one, status lists applied and pending migrations")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, with secrets redacted, and exit")
+	if err := logging.Configure(); err != nil {
+		logging.Error("invalid logging configuration", "error", err)
+		os.Exit(1)
+	}
+	if err := tracing.Configure("data-service"); err != nil {
+		logging.Error("invalid tracing configuration", "error", err)
+		os.Exit(1)
+	}
+	config, err := LoadConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		logging.Error("problem in loading the configuration", "error", err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		PrintConfig(config, os.Stdout)
+		return
+	}
+	if err := config.Validate(); err != nil {
+		logging.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	if *migrateCommand != "" && config.PeopleRepository != ORACLE_REPOSITORY {
+		logging.Error("schema migrations only apply to the oracle person repository", "peopleRepository", config.PeopleRepository)
+		os.Exit(1)
+	}
+	var bootstrap *oracledb.Bootstrap
+	switch config.PeopleRepository {
+	case ORACLE_REPOSITORY:
+		if *migrateCommand != "" {
+			db, err := oracledb.OpenWithRetry(context.Background(), config.Database)
+			if err != nil {
+				logging.Error("problem in connecting to the database", "error", err)
+				os.Exit(1)
+			}
+			defer db.Close()
+			err = schema.RunMigrationCommand(db, *migrateCommand, os.Stdout)
+			if err != nil {
+				logging.Error("schema migration failed", "command", *migrateCommand, "error", err)
+				os.Exit(1)
+			}
+			return
+		}
+		// the server starts right away, degraded and not ready, while the bootstrap connects and migrates the schema
+		bootstrap = oracledb.StartBootstrap(context.Background(), config.Database, InitializeDataServer)
+		repository = NewOracleRepository(bootstrap)
+		oracledb.RegisterPoolMetrics(bootstrap.Database)
+	case MEMORY_REPOSITORY:
+		repository = NewMemoryRepository()
+	case FILE_REPOSITORY:
+		repository, err = NewFileRepository(config.PeopleRepositoryFile)
 		if err != nil {
-			fmt.Println("Can't close connection: ", err)
+			logging.Error("problem in opening the person repository file", "file", config.PeopleRepositoryFile, "error", err)
+			os.Exit(1)
 		}
-	}()
-	InitializeDataServer(db)
-
-	httpServerPort, ok := os.LookupEnv(ENV_KEY_HTTP_SERVER_PORT)
-	if !ok {
-		httpServerPort = DEFAULT_HTTP_SERVER_PORT
-		log.Printf("Environment Variable %s not set; using default value: %s", ENV_KEY_HTTP_SERVER_PORT, DEFAULT_HTTP_SERVER_PORT)
-	}
-	myserverVersion, ok := os.LookupEnv(ENV_KEY_MYSERVER_VERSION)
-	if !ok {
-		myserverVersion = "unknown"
-		log.Printf("Environment Variable %s not set", ENV_KEY_MYSERVER_VERSION)
 	}
-	fileServer := http.FileServer(http.Dir("./website"))
-	http.Handle(STATIC_SITE_PATH, http.StripPrefix("/site/", fileServer))
-	http.HandleFunc(GREET_PATH, greetHandler)
-	http.HandleFunc(DATA_PATH, DataHandler)
-	http.HandleFunc(ROOT_PATH, fallbackHandler)
+	logging.Info("persons are stored in the repository", "peopleRepository", config.PeopleRepository)
 
-	log.Printf("Starting my-server (version %s) listening for requests at port %s\n", myserverVersion, httpServerPort)
-	if err := http.ListenAndServe(":"+httpServerPort, nil); err != nil {
-		log.Printf("serious problem and signing off %s", err)
-		log.Fatal(err)
+	logging.Info("starting my-server", "version", config.Version, "port", config.HTTPServerPort)
+	cleanups := []func() error{tracing.Shutdown}
+	if bootstrap != nil {
+		cleanups = append(cleanups, bootstrap.Close)
+	}
+	config.HTTP.PublicPaths = []string{httpserver.HEALTHZ_PATH, httpserver.READYZ_PATH, metrics.METRICS_PATH}
+	handler := httpserver.ServerHandler(newRouter(bootstrap), config.HTTP)
+	if err := httpserver.RunServer(httpserver.NewServer(config.HTTPServerPort, handler), time.Duration(config.ShutdownGracePeriod), cleanups...); err != nil {
+		logging.Error("serious problem and signing off", "error", err)
+		os.Exit(1)
 	}
+	logging.Info("my-server stopped")
 }
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go-on-oci-shared/httpserver"
+	"go-on-oci-shared/people"
+)
+
+// personPatch holds the fields of a PATCH request; fields that are not present in the request body remain nil
+type personPatch struct {
+	Name         *string `json:"name"`
+	Age          *int    `json:"age"`
+	JuicyDetails *string `json:"comment"`
+}
+
+// PeopleHandler handles the people collection at /people: GET lists a page of persons, POST creates a new person
+func PeopleHandler(response http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		listPeople(response, request)
+	case http.MethodPost:
+		createPerson(response, request)
+	default:
+		methodNotAllowed(response, http.MethodGet, http.MethodPost)
+	}
+}
+
+// PersonHandler handles a single person resource at /people/{name}; the name is the escaped path segment, so a name with a
+// slash in it is addressed as %2F, as personLocation writes it
+func PersonHandler(response http.ResponseWriter, request *http.Request) {
+	segment := strings.TrimPrefix(request.URL.EscapedPath(), PERSON_PATH)
+	if segment == "" {
+		PeopleHandler(response, request)
+		return
+	}
+	name, err := url.PathUnescape(segment)
+	if strings.Contains(segment, "/") || err != nil {
+		httpserver.WriteError(response, http.StatusNotFound, "not_found", fmt.Sprintf("No resource found at %s", request.URL.Path))
+		return
+	}
+	switch request.Method {
+	case http.MethodGet:
+		getPerson(response, request, name)
+	case http.MethodPut:
+		replacePerson(response, request, name)
+	case http.MethodPatch:
+		updatePerson(response, request, name)
+	case http.MethodDelete:
+		removePerson(response, request, name)
+	default:
+		methodNotAllowed(response, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func listPeople(response http.ResponseWriter, request *http.Request) {
+	query, err := parsePeopleQuery(request.URL.Query())
+	if err != nil {
+		httpserver.WriteError(response, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	page, err := repository.ListPeople(request.Context(), query)
+	if err != nil {
+		httpserver.WriteDatabaseError(response, request, err)
+		return
+	}
+	page.Next = nextPageLink(request.URL.Query(), query, page)
+	httpserver.WriteJSON(response, http.StatusOK, page)
+}
+
+func getPerson(response http.ResponseWriter, request *http.Request, name string) {
+	person, err := repository.GetPerson(request.Context(), name)
+	if err == ErrPersonNotFound {
+		httpserver.WriteError(response, http.StatusNotFound, "not_found", fmt.Sprintf("No person found with name %s", name))
+		return
+	}
+	if err != nil {
+		httpserver.WriteDatabaseError(response, request, err)
+		return
+	}
+	response.Header().Set("ETag", personETag(person))
+	if etagMatches(request.Header.Get("If-None-Match"), &person, true) {
+		response.WriteHeader(http.StatusNotModified)
+		return
+	}
+	httpserver.WriteJSON(response, http.StatusOK, person)
+}
+
+func createPerson(response http.ResponseWriter, request *http.Request) {
+	var person Person
+	// Try to decode the request body into the struct. If there is an error,
+	// respond to the client with the error message and a 400 status code.
+	err := json.NewDecoder(request.Body).Decode(&person)
+	if err != nil {
+		httpserver.WriteError(response, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if validationErrors := people.Validate(person.Name, person.Age, person.JuicyDetails); len(validationErrors) > 0 {
+		httpserver.WriteValidationError(response, people.INVALID_PERSON_MESSAGE, validationErrors)
+		return
+	}
+	person, err = repository.SavePerson(request.Context(), person, WriteCondition{MustNotExist: true})
+	if err == ErrPreconditionFailed {
+		httpserver.WriteError(response, http.StatusConflict, "already_exists", fmt.Sprintf("A person with name %s already exists", person.Name))
+		return
+	}
+	if err != nil {
+		httpserver.WriteDatabaseError(response, request, err)
+		return
+	}
+	response.Header().Set("Location", personLocation(person.Name))
+	writeStoredPerson(response, http.StatusCreated, person)
+}
+
+func replacePerson(response http.ResponseWriter, request *http.Request, name string) {
+	var person Person
+	err := json.NewDecoder(request.Body).Decode(&person)
+	if err != nil {
+		httpserver.WriteError(response, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if person.Name != "" && person.Name != name {
+		httpserver.WriteError(response, http.StatusBadRequest, "invalid_request", fmt.Sprintf("Name %s in body does not match name %s in path", person.Name, name))
+		return
+	}
+	person.Name = name
+	if validationErrors := people.Validate(person.Name, person.Age, person.JuicyDetails); len(validationErrors) > 0 {
+		httpserver.WriteValidationError(response, people.INVALID_PERSON_MESSAGE, validationErrors)
+		return
+	}
+	current, err := currentPerson(request, name)
+	if err != nil {
+		httpserver.WriteDatabaseError(response, request, err)
+		return
+	}
+	condition, ok := writeConditionFor(request, current)
+	if !ok {
+		writePreconditionFailed(response, name)
+		return
+	}
+	person, err = repository.SavePerson(request.Context(), person, condition)
+	if err == ErrPreconditionFailed {
+		writePreconditionFailed(response, name)
+		return
+	}
+	if err != nil {
+		httpserver.WriteDatabaseError(response, request, err)
+		return
+	}
+	if current == nil {
+		response.Header().Set("Location", personLocation(name))
+		writeStoredPerson(response, http.StatusCreated, person)
+		return
+	}
+	writeStoredPerson(response, http.StatusOK, person)
+}
+
+func updatePerson(response http.ResponseWriter, request *http.Request, name string) {
+	var patch personPatch
+	err := json.NewDecoder(request.Body).Decode(&patch)
+	if err != nil {
+		httpserver.WriteError(response, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if patch.Name != nil && *patch.Name != name {
+		httpserver.WriteError(response, http.StatusBadRequest, "invalid_request", "The name of a person cannot be changed")
+		return
+	}
+	current, err := currentPerson(request, name)
+	if err != nil {
+		httpserver.WriteDatabaseError(response, request, err)
+		return
+	}
+	if current == nil {
+		httpserver.WriteError(response, http.StatusNotFound, "not_found", fmt.Sprintf("No person found with name %s", name))
+		return
+	}
+	condition, ok := writeConditionFor(request, current)
+	if !ok {
+		writePreconditionFailed(response, name)
+		return
+	}
+	// the patch is applied to the version that was read, so the update must not overwrite a later version
+	condition.Version = current.Version
+	person := *current
+	if patch.Age != nil {
+		person.Age = *patch.Age
+	}
+	if patch.JuicyDetails != nil {
+		person.JuicyDetails = *patch.JuicyDetails
+	}
+	if validationErrors := people.Validate(person.Name, person.Age, person.JuicyDetails); len(validationErrors) > 0 {
+		httpserver.WriteValidationError(response, people.INVALID_PERSON_MESSAGE, validationErrors)
+		return
+	}
+	person, err = repository.SavePerson(request.Context(), person, condition)
+	if err == ErrPreconditionFailed {
+		writePreconditionFailed(response, name)
+		return
+	}
+	if err != nil {
+		httpserver.WriteDatabaseError(response, request, err)
+		return
+	}
+	writeStoredPerson(response, http.StatusOK, person)
+}
+
+func removePerson(response http.ResponseWriter, request *http.Request, name string) {
+	current, err := currentPerson(request, name)
+	if err != nil {
+		httpserver.WriteDatabaseError(response, request, err)
+		return
+	}
+	condition, ok := writeConditionFor(request, current)
+	if !ok {
+		writePreconditionFailed(response, name)
+		return
+	}
+	if current == nil {
+		httpserver.WriteError(response, http.StatusNotFound, "not_found", fmt.Sprintf("No person found with name %s", name))
+		return
+	}
+	err = repository.DeletePerson(request.Context(), name, condition)
+	if err == ErrPreconditionFailed {
+		writePreconditionFailed(response, name)
+		return
+	}
+	if err == ErrPersonNotFound {
+		httpserver.WriteError(response, http.StatusNotFound, "not_found", fmt.Sprintf("No person found with name %s", name))
+		return
+	}
+	if err != nil {
+		httpserver.WriteDatabaseError(response, request, err)
+		return
+	}
+	response.WriteHeader(http.StatusNoContent)
+}
+
+// currentPerson returns the person with the given name as currently stored, or nil when there is no such person
+func currentPerson(request *http.Request, name string) (*Person, error) {
+	person, err := repository.GetPerson(request.Context(), name)
+	if err == ErrPersonNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &person, nil
+}
+
+func writeStoredPerson(response http.ResponseWriter, status int, person Person) {
+	response.Header().Set("ETag", personETag(person))
+	httpserver.WriteJSON(response, status, person)
+}
+
+func personLocation(name string) string {
+	return PERSON_PATH + url.PathEscape(name)
+}
+
+func methodNotAllowed(response http.ResponseWriter, allowedMethods ...string) {
+	response.Header().Set("Allow", strings.Join(allowedMethods, ", "))
+	httpserver.MethodNotAllowedHandler(response, nil)
+}
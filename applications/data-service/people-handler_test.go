@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go-on-oci-shared/httpserver"
+	"go-on-oci-shared/people"
+)
+
+func peopleServer() http.Handler {
+	repository = NewMemoryRepository()
+	return newRouter(nil)
+}
+
+func send(mux http.Handler, method string, path string, body string, headers ...string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+	response := httptest.NewRecorder()
+	mux.ServeHTTP(response, request)
+	return response
+}
+
+func TestPersonLifecycle(t *testing.T) {
+	mux := peopleServer()
+	cases := []struct {
+		method, path, body string
+		headers            []string
+		status             int
+		etag               string
+	}{
+		{"POST", "/people", `{"name":"Mary","age":42,"comment":"likes Go"}`, nil, http.StatusCreated, `"1"`},
+		{"POST", "/people", `{"name":"Mary","age":43}`, nil, http.StatusConflict, ""},
+		{"GET", "/people/Mary", "", nil, http.StatusOK, `"1"`},
+		{"GET", "/people/Mary", "", []string{"If-None-Match", `"1"`}, http.StatusNotModified, `"1"`},
+		{"GET", "/people/Mary", "", []string{"If-None-Match", `W/"1"`}, http.StatusNotModified, `"1"`},
+		{"GET", "/people/Mary", "", []string{"If-None-Match", `W/"7", "8"`}, http.StatusOK, `"1"`},
+		{"PUT", "/people/Mary", `{"age":43}`, []string{"If-Match", `W/"1"`}, http.StatusPreconditionFailed, ""},
+		{"PUT", "/people/Mary", `{"age":43}`, []string{"If-None-Match", `W/"1"`}, http.StatusPreconditionFailed, ""},
+		{"PUT", "/people/Mary", `{"age":43}`, []string{"If-Match", `"1"`}, http.StatusOK, `"2"`},
+		{"PUT", "/people/Mary", `{"age":44}`, []string{"If-Match", `"1"`}, http.StatusPreconditionFailed, ""},
+		{"PUT", "/people/Mary", `{"age":44}`, []string{"If-None-Match", "*"}, http.StatusPreconditionFailed, ""},
+		{"PATCH", "/people/Mary", `{"comment":"likes Go a lot"}`, nil, http.StatusOK, `"3"`},
+		{"PATCH", "/people/John", `{"age":12}`, nil, http.StatusNotFound, ""},
+		{"PUT", "/people/John", `{"age":12}`, nil, http.StatusCreated, `"1"`},
+		{"DELETE", "/people/Mary", "", []string{"If-Match", `"2"`}, http.StatusPreconditionFailed, ""},
+		{"DELETE", "/people/Mary", "", []string{"If-Match", `"3"`}, http.StatusNoContent, ""},
+		{"GET", "/people/Mary", "", nil, http.StatusNotFound, ""},
+		{"DELETE", "/people/Mary", "", nil, http.StatusNotFound, ""},
+		{"POST", "/people", `{"name":"","age":1000}`, nil, http.StatusUnprocessableEntity, ""},
+		{"POST", "/people/John", `{}`, nil, http.StatusMethodNotAllowed, ""},
+		{"PUT", "/people", `{}`, nil, http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, c := range cases {
+		response := send(mux, c.method, c.path, c.body, c.headers...)
+		if response.Code != c.status {
+			t.Fatalf("%s %s: want status %d, got %d (%s)\n", c.method, c.path, c.status, response.Code, response.Body.String())
+		}
+		if etag := response.Header().Get("ETag"); etag != c.etag {
+			t.Fatalf("%s %s: want ETag %s, got %s\n", c.method, c.path, c.etag, etag)
+		}
+	}
+}
+
+func TestListPeople(t *testing.T) {
+	mux := peopleServer()
+	for _, person := range []string{`{"name":"Anna","age":30}`, `{"name":"Bob","age":20}`, `{"name":"Carl","age":40}`, `{"name":"Cleo","age":20}`, `{"name":"Dirk","age":50}`} {
+		send(mux, "POST", "/people", person)
+	}
+	cases := []struct {
+		query    string
+		expected string
+		total    int
+	}{
+		{"", "Anna,Bob,Carl,Cleo,Dirk", 5},
+		{"?sort=-age", "Dirk,Carl,Anna,Cleo,Bob", 5},
+		{"?sort=age&limit=2", "Bob,Cleo|Anna,Carl|Dirk", 5},
+		{"?limit=2&offset=0", "Anna,Bob|Carl,Cleo|Dirk", 5},
+		{"?minAge=25&maxAge=45", "Anna,Carl", 2},
+		{"?namePrefix=C", "Carl,Cleo", 2},
+	}
+
+	for _, c := range cases {
+		var pages []string
+		next := "/people" + c.query
+		for next != "" {
+			response := send(mux, "GET", next, "")
+			var page PeoplePage
+			if err := json.Unmarshal(response.Body.Bytes(), &page); err != nil {
+				t.Fatalf("%s: %s (%s)\n", next, err, response.Body.String())
+			}
+			if page.Total != c.total {
+				t.Fatalf("%s: want total %d, got %d\n", next, c.total, page.Total)
+			}
+			names := make([]string, len(page.Items))
+			for i, person := range page.Items {
+				names[i] = person.Name
+			}
+			pages = append(pages, strings.Join(names, ","))
+			next = page.Next
+		}
+		if result := strings.Join(pages, "|"); result != c.expected {
+			t.Fatalf("%s: want %s, got %s\n", c.query, c.expected, result)
+		}
+	}
+}
+
+func TestPersonLocationRoundTrip(t *testing.T) {
+	mux := peopleServer()
+	for _, name := range []string{"AC/DC", "Mary Jane", "50%", "Zoë"} {
+		body, _ := json.Marshal(Person{Name: name, Age: 40})
+		response := send(mux, "POST", "/people", string(body))
+		location := response.Header().Get("Location")
+		if response.Code != http.StatusCreated || location == "" {
+			t.Fatalf("%s: want 201 with Location, got %d %s\n", name, response.Code, response.Body.String())
+		}
+		response = send(mux, "GET", location, "")
+		var person Person
+		json.Unmarshal(response.Body.Bytes(), &person)
+		if response.Code != http.StatusOK || person.Name != name {
+			t.Fatalf("%s: want GET %s to return the person, got %d %s\n", name, location, response.Code, response.Body.String())
+		}
+	}
+	if response := send(mux, "GET", "/people/AC/DC", ""); response.Code != http.StatusNotFound {
+		t.Fatalf("want an unescaped slash to address no person, got %d\n", response.Code)
+	}
+	if response := send(mux, "DELETE", "/people/AC%2FDC", ""); response.Code != http.StatusNoContent {
+		t.Fatalf("want the person with a slash in the name deleted, got %d %s\n", response.Code, response.Body.String())
+	}
+}
+
+func TestPersonTimestamps(t *testing.T) {
+	mux := peopleServer()
+	decode := func(response *httptest.ResponseRecorder) Person {
+		var person Person
+		if err := json.Unmarshal(response.Body.Bytes(), &person); err != nil || person.CreatedAt == nil || person.UpdatedAt == nil {
+			t.Fatalf("want createdAt and updatedAt, got %s (%v)\n", response.Body.String(), err)
+		}
+		return person
+	}
+	created := decode(send(mux, "POST", "/people", `{"name":"Mary","age":42}`))
+	if !created.CreatedAt.Equal(*created.UpdatedAt) {
+		t.Fatalf("want a new person updated when created, got %v and %v\n", created.CreatedAt, created.UpdatedAt)
+	}
+	time.Sleep(time.Millisecond)
+	updated := decode(send(mux, "PATCH", "/people/Mary", `{"age":43}`))
+	if !updated.CreatedAt.Equal(*created.CreatedAt) || !updated.UpdatedAt.After(*created.UpdatedAt) {
+		t.Fatalf("want createdAt kept and updatedAt advanced, got %v and %v\n", updated.CreatedAt, updated.UpdatedAt)
+	}
+	if read := decode(send(mux, "GET", "/people/Mary", "")); !read.UpdatedAt.Equal(*updated.UpdatedAt) {
+		t.Fatalf("want GET to return the updatedAt of the last write, got %v\n", read.UpdatedAt)
+	}
+	if !strings.Contains(send(mux, "GET", "/people/Mary", "").Body.String(), `"createdAt":"`) {
+		t.Fatal("want the timestamps in the JSON as createdAt and updatedAt")
+	}
+}
+
+func TestPersonValidation(t *testing.T) {
+	mux := peopleServer()
+	send(mux, "POST", "/people", `{"name":"Mary","age":42}`)
+	cases := []struct {
+		method, path, body string
+		fields             map[string]string
+	}{
+		{"POST", "/people", `{"name":" ","age":-1}`, map[string]string{"name": "name is required", "age": "age cannot be negative"}},
+		{"PUT", "/people/John", `{"age":1000}`, map[string]string{"age": "age cannot be over 999"}},
+		{"PATCH", "/people/Mary", `{"comment":"` + strings.Repeat("c", people.MAX_COMMENT_LENGTH+1) + `"}`, map[string]string{"comment": "comment cannot be longer than 1000 bytes"}},
+	}
+	for _, c := range cases {
+		response := send(mux, c.method, c.path, c.body)
+		var errorResponse httpserver.ErrorResponse
+		json.Unmarshal(response.Body.Bytes(), &errorResponse)
+		if response.Code != http.StatusUnprocessableEntity || errorResponse.Code != "validation_failed" || !reflect.DeepEqual(errorResponse.Fields, c.fields) {
+			t.Fatalf("%s %s: want 422 with fields %v, got %d %s\n", c.method, c.path, c.fields, response.Code, response.Body.String())
+		}
+	}
+	if response := send(mux, "GET", "/people/Mary", ""); !strings.Contains(response.Body.String(), `"age":42`) {
+		t.Fatalf("want the rejected changes to leave Mary alone, got %s\n", response.Body.String())
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-on-oci-shared/oracledb"
+)
+
+const (
+	DEFAULT_PAGE_LIMIT = 50
+	MAX_PAGE_LIMIT     = 500
+)
+
+// sortableColumns maps the sort keys accepted in the sort query parameter to the (null safe) column expressions they order on;
+// only these expressions ever end up in the SQL text, all values are passed as bind variables
+var sortableColumns = map[string]string{
+	"name":      "name",
+	"age":       "nvl(age, -1)",
+	"comment":   "nvl(description, ' ')",
+	"createdAt": "nvl(creation_time, timestamp '1970-01-01 00:00:00')",
+	"updatedAt": "nvl(updated_time, timestamp '1970-01-01 00:00:00')",
+}
+
+// PeopleQuery describes a page of persons to retrieve: filters, sort order and either an offset or a keyset cursor
+type PeopleQuery struct {
+	Limit         int
+	Offset        int
+	Cursor        *peopleCursor
+	MinAge        *int
+	MaxAge        *int
+	NamePrefix    string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	SortBy        string
+	Descending    bool
+}
+
+// PeoplePage is one page of persons along with the total number of persons matching the filters and a link to the next page
+type PeoplePage struct {
+	Items      []Person `json:"items"`
+	Total      int      `json:"total"`
+	Limit      int      `json:"limit"`
+	Offset     int      `json:"offset"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+	Next       string   `json:"next,omitempty"`
+}
+
+// peopleCursor identifies the last row of a page for keyset pagination: the value of the sort column and the name as tie breaker
+type peopleCursor struct {
+	SortBy     string      `json:"s"`
+	Descending bool        `json:"d"`
+	Value      interface{} `json:"v"`
+	Name       string      `json:"n"`
+}
+
+func (cursor peopleCursor) encode() string {
+	cursorJson, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(cursorJson)
+}
+
+func decodePeopleCursor(encodedCursor string) (*peopleCursor, error) {
+	cursorJson, err := base64.RawURLEncoding.DecodeString(encodedCursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	var cursor peopleCursor
+	err = json.Unmarshal(cursorJson, &cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if _, ok := sortableColumns[cursor.SortBy]; !ok {
+		return nil, fmt.Errorf("invalid cursor: unknown sort key %s", cursor.SortBy)
+	}
+	return &cursor, nil
+}
+
+// bindValue converts the sort value kept in a cursor into the Go type matching the sort column
+func (cursor peopleCursor) bindValue() (interface{}, error) {
+	switch cursor.SortBy {
+	case "age":
+		age, ok := cursor.Value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("invalid cursor: age value is not a number")
+		}
+		return int(age), nil
+	case "createdAt", "updatedAt":
+		timestamp, ok := cursor.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid cursor: %s value is not a timestamp", cursor.SortBy)
+		}
+		return time.Parse(time.RFC3339Nano, timestamp)
+	default:
+		value, ok := cursor.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid cursor: %s value is not a string", cursor.SortBy)
+		}
+		return value, nil
+	}
+}
+
+// parsePeopleQuery reads limit, offset, cursor, minAge, maxAge, namePrefix, createdAfter, createdBefore and sort
+// from the query parameters; sort takes a sort key, prefixed with - for descending order
+func parsePeopleQuery(values url.Values) (PeopleQuery, error) {
+	query := PeopleQuery{Limit: DEFAULT_PAGE_LIMIT, SortBy: "name"}
+	var err error
+	if limit := values.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 1 || query.Limit > MAX_PAGE_LIMIT {
+			return query, fmt.Errorf("limit must be a number between 1 and %d", MAX_PAGE_LIMIT)
+		}
+	}
+	if offset := values.Get("offset"); offset != "" {
+		query.Offset, err = strconv.Atoi(offset)
+		if err != nil || query.Offset < 0 {
+			return query, fmt.Errorf("offset must be a non negative number")
+		}
+	}
+	if sort := values.Get("sort"); sort != "" {
+		query.Descending = strings.HasPrefix(sort, "-")
+		query.SortBy = strings.TrimPrefix(sort, "-")
+		if _, ok := sortableColumns[query.SortBy]; !ok {
+			return query, fmt.Errorf("cannot sort on %s; sort on one of name, age, comment, createdAt or updatedAt", query.SortBy)
+		}
+	}
+	if cursor := values.Get("cursor"); cursor != "" {
+		if query.Offset > 0 {
+			return query, fmt.Errorf("offset and cursor cannot be combined")
+		}
+		query.Cursor, err = decodePeopleCursor(cursor)
+		if err != nil {
+			return query, err
+		}
+		query.SortBy = query.Cursor.SortBy
+		query.Descending = query.Cursor.Descending
+	}
+	if query.MinAge, err = parseOptionalInt(values, "minAge"); err != nil {
+		return query, err
+	}
+	if query.MaxAge, err = parseOptionalInt(values, "maxAge"); err != nil {
+		return query, err
+	}
+	query.NamePrefix = values.Get("namePrefix")
+	if query.CreatedAfter, err = parseOptionalTime(values, "createdAfter"); err != nil {
+		return query, err
+	}
+	if query.CreatedBefore, err = parseOptionalTime(values, "createdBefore"); err != nil {
+		return query, err
+	}
+	return query, nil
+}
+
+func parseOptionalInt(values url.Values, parameter string) (*int, error) {
+	value := values.Get(parameter)
+	if value == "" {
+		return nil, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number", parameter)
+	}
+	return &number, nil
+}
+
+func parseOptionalTime(values url.Values, parameter string) (*time.Time, error) {
+	value := values.Get(parameter)
+	if value == "" {
+		return nil, nil
+	}
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp such as 2022-06-01T00:00:00Z", parameter)
+	}
+	return &timestamp, nil
+}
+
+// sqlBinds collects bind values and hands out a unique placeholder for each of them,
+// in the order in which they appear in the statement
+type sqlBinds struct {
+	args []interface{}
+}
+
+func (binds *sqlBinds) bind(value interface{}) string {
+	binds.args = append(binds.args, value)
+	return fmt.Sprintf(":p%d", len(binds.args))
+}
+
+func (query PeopleQuery) whereClause(binds *sqlBinds) string {
+	conditions := []string{"1 = 1"}
+	if query.MinAge != nil {
+		conditions = append(conditions, "age >= "+binds.bind(*query.MinAge))
+	}
+	if query.MaxAge != nil {
+		conditions = append(conditions, "age <= "+binds.bind(*query.MaxAge))
+	}
+	if query.NamePrefix != "" {
+		conditions = append(conditions, "name like "+binds.bind(escapeLike(query.NamePrefix)+"%")+` escape '\'`)
+	}
+	if query.CreatedAfter != nil {
+		conditions = append(conditions, "creation_time >= "+binds.bind(*query.CreatedAfter))
+	}
+	if query.CreatedBefore != nil {
+		conditions = append(conditions, "creation_time < "+binds.bind(*query.CreatedBefore))
+	}
+	return strings.Join(conditions, " and ")
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+func (query PeopleQuery) keysetCondition(binds *sqlBinds) (string, error) {
+	value, err := query.Cursor.bindValue()
+	if err != nil {
+		return "", err
+	}
+	comparison := ">"
+	if query.Descending {
+		comparison = "<"
+	}
+	if query.SortBy == "name" {
+		return fmt.Sprintf("name %s %s", comparison, binds.bind(value)), nil
+	}
+	column := sortableColumns[query.SortBy]
+	return fmt.Sprintf("(%s %s %s or (%s = %s and name %s %s))",
+		column, comparison, binds.bind(value), column, binds.bind(value), comparison, binds.bind(query.Cursor.Name)), nil
+}
+
+func (query PeopleQuery) orderByClause() string {
+	direction := "asc"
+	if query.Descending {
+		direction = "desc"
+	}
+	if query.SortBy == "name" {
+		return "name " + direction
+	}
+	return fmt.Sprintf("%s %s, name %s", sortableColumns[query.SortBy], direction, direction)
+}
+
+// sortKey returns the value a person is sorted on, with the same handling of empty values as the column expressions in
+// sortableColumns: -1 for a NULL age, a space for an empty comment
+func (query PeopleQuery) sortKey(person Person) interface{} {
+	switch query.SortBy {
+	case "age":
+		if person.ageUnknown {
+			return -1
+		}
+		return person.Age
+	case "comment":
+		if person.JuicyDetails == "" {
+			return " "
+		}
+		return person.JuicyDetails
+	case "createdAt":
+		return timestampSortKey(person.CreatedAt)
+	case "updatedAt":
+		return timestampSortKey(person.UpdatedAt)
+	default:
+		return person.Name
+	}
+}
+
+func timestampSortKey(timestamp *time.Time) time.Time {
+	if timestamp == nil {
+		return time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return *timestamp
+}
+
+// nextCursor returns the cursor for the page that follows the given last person of a page
+func (query PeopleQuery) nextCursor(lastPerson Person) string {
+	value := query.sortKey(lastPerson)
+	if timestamp, ok := value.(time.Time); ok {
+		value = timestamp.Format(time.RFC3339Nano)
+	}
+	return peopleCursor{SortBy: query.SortBy, Descending: query.Descending, Value: value, Name: lastPerson.Name}.encode()
+}
+
+func (repository *oracleRepository) ListPeople(ctx context.Context, query PeopleQuery) (PeoplePage, error) {
+	page := PeoplePage{Items: []Person{}, Limit: query.Limit, Offset: query.Offset}
+	countBinds := &sqlBinds{}
+	countStatement := fmt.Sprintf(`select count(*) from %s where %s`, PEOPLE_TABLE_NAME, query.whereClause(countBinds))
+	database, err := repository.database()
+	if err != nil {
+		return page, err
+	}
+	done := oracledb.TraceStatement(ctx, PEOPLE_TABLE_NAME, "countPeople", "count")
+	err = database.QueryRowContext(ctx, countStatement, countBinds.args...).Scan(&page.Total)
+	done(err)
+	if err != nil {
+		return page, err
+	}
+
+	binds := &sqlBinds{}
+	where := query.whereClause(binds)
+	if query.Cursor != nil {
+		keyset, err := query.keysetCondition(binds)
+		if err != nil {
+			return page, err
+		}
+		where += " and " + keyset
+	}
+	// one row more than the limit is fetched to find out whether there is a next page
+	selectStatement := fmt.Sprintf(
+		`select name, age, description, creation_time, updated_time from %s where %s order by %s offset %s rows fetch next %s rows only`,
+		PEOPLE_TABLE_NAME, where, query.orderByClause(), binds.bind(query.Offset), binds.bind(query.Limit+1))
+	done = oracledb.TraceStatement(ctx, PEOPLE_TABLE_NAME, "listPeople", "list")
+	rows, err := database.QueryContext(ctx, selectStatement, binds.args...)
+	// done when the rows have been fetched, so the time and errors of fetching count as well
+	defer func() { done(err) }()
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+	hasMore := false
+	for rows.Next() {
+		if len(page.Items) == query.Limit {
+			hasMore = true
+			break
+		}
+		var person Person
+		var age sql.NullInt64
+		var description sql.NullString
+		var creationTime, updatedTime sql.NullTime
+		err = rows.Scan(&person.Name, &age, &description, &creationTime, &updatedTime)
+		if err != nil {
+			return page, err
+		}
+		person.Age = int(age.Int64)
+		person.ageUnknown = !age.Valid
+		person.JuicyDetails = description.String
+		person.CreatedAt = nullableTime(creationTime)
+		person.UpdatedAt = nullableTime(updatedTime)
+		page.Items = append(page.Items, person)
+	}
+	if err = rows.Err(); err != nil {
+		return page, err
+	}
+	if hasMore {
+		page.NextCursor = query.nextCursor(page.Items[len(page.Items)-1])
+	}
+	return page, nil
+}
+
+// nextPageLink composes the link to the page following the current one: offset based when the request used an offset,
+// cursor based otherwise
+func nextPageLink(values url.Values, query PeopleQuery, page PeoplePage) string {
+	if page.NextCursor == "" {
+		return ""
+	}
+	next := url.Values{}
+	for parameter, parameterValues := range values {
+		next[parameter] = parameterValues
+	}
+	if query.Cursor == nil && values.Get("offset") != "" {
+		next.Set("offset", strconv.Itoa(query.Offset+len(page.Items)))
+	} else {
+		next.Del("offset")
+		next.Del("sort")
+		next.Set("cursor", page.NextCursor)
+	}
+	return PEOPLE_PATH + "?" + next.Encode()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePeopleQueryRejectsInvalidParameters(t *testing.T) {
+	cases := []string{
+		"limit=0", "limit=501", "limit=ten", "offset=-1", "sort=shoeSize", "sort=-password",
+		"minAge=old", "maxAge=1.5", "createdAfter=yesterday", "cursor=not-a-cursor", "offset=5&cursor=" + peopleCursor{SortBy: "name", Name: "Mary", Value: "Mary"}.encode(),
+		"cursor=" + peopleCursor{SortBy: "description; drop table people", Name: "Mary"}.encode(),
+	}
+	mux := peopleServer()
+	for _, query := range cases {
+		if response := send(mux, "GET", "/people?"+query, ""); response.Code != 400 || !strings.Contains(response.Body.String(), "invalid_request") {
+			t.Fatalf("%s: want 400 invalid_request, got %d %s\n", query, response.Code, response.Body.String())
+		}
+	}
+}
+
+func TestPeopleQuerySQL(t *testing.T) {
+	values := url.Values{"minAge": {"18"}, "maxAge": {"65"}, "namePrefix": {"50%_"}, "createdAfter": {"2022-06-01T00:00:00Z"}, "sort": {"-age"}}
+	query, err := parsePeopleQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binds := &sqlBinds{}
+	where := query.whereClause(binds)
+	wantWhere := `1 = 1 and age >= :p1 and age <= :p2 and name like :p3 escape '\' and creation_time >= :p4`
+	if where != wantWhere {
+		t.Fatalf("want where clause\n%s\ngot\n%s\n", wantWhere, where)
+	}
+	createdAfter := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	if wantArgs := []interface{}{18, 65, `50\%\_%`, createdAfter}; !reflect.DeepEqual(binds.args, wantArgs) {
+		t.Fatalf("want binds %v, got %v\n", wantArgs, binds.args)
+	}
+	if orderBy := query.orderByClause(); orderBy != "nvl(age, -1) desc, name desc" {
+		t.Fatalf("unexpected order by %s\n", orderBy)
+	}
+
+	// the cursor of the last person of a page continues after that person, with the name breaking ties on the sort value
+	query.Cursor, err = decodePeopleCursor(query.nextCursor(Person{Name: "Mary", Age: 42}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	binds = &sqlBinds{}
+	keyset, err := query.keysetCondition(binds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyset != "(nvl(age, -1) < :p1 or (nvl(age, -1) = :p2 and name < :p3))" || !reflect.DeepEqual(binds.args, []interface{}{42, 42, "Mary"}) {
+		t.Fatalf("unexpected keyset condition %s with %v\n", keyset, binds.args)
+	}
+}
+
+func TestNextPageLink(t *testing.T) {
+	values, _ := url.ParseQuery("limit=2&namePrefix=C&sort=age")
+	query, _ := parsePeopleQuery(values)
+	page := PeoplePage{Items: []Person{{Name: "Carl", Age: 40}, {Name: "Cleo", Age: 20}}, NextCursor: "abc"}
+	if next := nextPageLink(values, query, page); next != "/people?cursor=abc&limit=2&namePrefix=C" {
+		t.Fatalf("want the cursor to replace sort, got %s\n", next)
+	}
+	values.Set("offset", "4")
+	query, _ = parsePeopleQuery(values)
+	if next := nextPageLink(values, query, page); next != "/people?limit=2&namePrefix=C&offset=6&sort=age" {
+		t.Fatalf("want the offset advanced by the page, got %s\n", next)
+	}
+	if next := nextPageLink(values, query, PeoplePage{}); next != "" {
+		t.Fatalf("want no link after the last page, got %s\n", next)
+	}
+}
+
+func TestCursorPagingKeepsUnknownAndZeroAges(t *testing.T) {
+	mux := peopleServer()
+	memory := repository.(*memoryRepository)
+	for _, person := range []Person{{Name: "Ann", ageUnknown: true}, {Name: "Bob", Age: 0}, {Name: "Cid", ageUnknown: true}, {Name: "Dee", Age: 0}, {Name: "Eve", Age: 30}} {
+		memory.people[person.Name] = person
+	}
+	values, _ := url.ParseQuery("limit=1&sort=age")
+	query, err := parsePeopleQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for page := 0; page < 10; page++ {
+		result, err := memory.ListPeople(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, person := range result.Items {
+			names = append(names, person.Name)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		if query.Cursor, err = decodePeopleCursor(result.NextCursor); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"Ann", "Cid", "Bob", "Dee", "Eve"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("want pages %v, got %v\n", want, names)
+	}
+	if response := send(mux, "GET", "/people?minAge=0", ""); strings.Contains(response.Body.String(), "Ann") {
+		t.Fatalf("want the age filter to leave out persons without an age, got %s\n", response.Body.String())
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go-on-oci-shared/httpserver"
+)
+
+// personETag derives the (strong) entity tag of a person from its row version
+func personETag(person Person) string {
+	return fmt.Sprintf(`"%d"`, person.Version)
+}
+
+// etagMatches reports whether the value of an If-Match or If-None-Match header matches the entity tag of the current person;
+// * matches any existing person, a list of entity tags matches when one of them equals the current entity tag. The weak
+// comparison of If-None-Match ignores the W/ prefix of weak entity tags, the strong comparison of If-Match never matches them
+// (RFC 9110, section 8.8.3.2).
+func etagMatches(headerValue string, current *Person, weak bool) bool {
+	if current == nil {
+		return false
+	}
+	currentETag := personETag(*current)
+	for _, etag := range strings.Split(headerValue, ",") {
+		etag = strings.TrimSpace(etag)
+		if weak {
+			etag = strings.TrimPrefix(etag, "W/")
+		}
+		if etag == "*" || etag == currentETag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeConditionFor evaluates the If-Match and If-None-Match headers of a write request against the current state of the person
+// (nil when it does not exist). It returns false when a precondition fails; otherwise the condition that makes the write fail
+// when the person is changed by someone else in the meantime.
+func writeConditionFor(request *http.Request, current *Person) (WriteCondition, bool) {
+	condition := WriteCondition{}
+	if ifMatch := request.Header.Get("If-Match"); ifMatch != "" {
+		if !etagMatches(ifMatch, current, false) {
+			return condition, false
+		}
+		condition.Version = current.Version
+	}
+	if ifNoneMatch := request.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if etagMatches(ifNoneMatch, current, true) {
+			return condition, false
+		}
+		if strings.TrimSpace(ifNoneMatch) == "*" {
+			condition.MustNotExist = true
+		}
+	}
+	return condition, true
+}
+
+func writePreconditionFailed(response http.ResponseWriter, name string) {
+	httpserver.WriteError(response, http.StatusPreconditionFailed, "precondition_failed",
+		fmt.Sprintf("The person %s does not match the If-Match or If-None-Match condition; it may have been changed by someone else", name))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// PersonRepository stores and retrieves persons; the handlers for /people only talk to a repository,
+// so the service can run against the Oracle Database as well as against memory or a local file
+type PersonRepository interface {
+	// GetPerson returns the person with the given name, or ErrPersonNotFound
+	GetPerson(ctx context.Context, name string) (Person, error)
+	// ListPeople returns the page of persons selected by the query
+	ListPeople(ctx context.Context, query PeopleQuery) (PeoplePage, error)
+	// SavePerson creates or replaces a person, subject to the condition, and returns the person as stored (with timestamps and version)
+	SavePerson(ctx context.Context, person Person, condition WriteCondition) (Person, error)
+	// DeletePerson removes a person, subject to the condition, or returns ErrPersonNotFound
+	DeletePerson(ctx context.Context, name string, condition WriteCondition) error
+}
+
+const (
+	ORACLE_REPOSITORY = "oracle"
+	MEMORY_REPOSITORY = "memory"
+	FILE_REPOSITORY   = "file"
+
+	ENV_KEY_PEOPLE_REPOSITORY      = "PEOPLE_REPOSITORY"
+	ENV_KEY_PEOPLE_REPOSITORY_FILE = "PEOPLE_REPOSITORY_FILE"
+	DEFAULT_PEOPLE_REPOSITORY_FILE = "people.json"
+)
+
+// WriteCondition restricts a write to a specific state of the record: Version is the row version the record must still have
+// (0 for no restriction) and MustNotExist only allows the write to create a new record
+type WriteCondition struct {
+	Version      int
+	MustNotExist bool
+}
+
+var (
+	// ErrPersonNotFound is returned when there is no person with the requested name
+	ErrPersonNotFound = errors.New("person not found")
+	// ErrPreconditionFailed is returned when a record no longer is in the state required by the WriteCondition of a write
+	ErrPreconditionFailed = errors.New("the person was changed or created by someone else")
+)
+
+var repository PersonRepository
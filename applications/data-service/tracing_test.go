@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-on-oci-shared/httpserver"
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/oracledb"
+	"go-on-oci-shared/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingMiddlewareContinuesTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	defaultProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(defaultProvider)
+
+	router := httpserver.NewRouter()
+	router.HandleFunc("/tracing-test/", func(response http.ResponseWriter, request *http.Request) {
+		done := oracledb.TraceStatement(request.Context(), PEOPLE_TABLE_NAME, "retrievePerson", "select")
+		done(sql.ErrNoRows)
+		done = oracledb.TraceStatement(request.Context(), PEOPLE_TABLE_NAME, "deletePerson", "delete")
+		done(errors.New("ORA-00054: resource busy"))
+		http.Error(response, "unavailable", http.StatusServiceUnavailable)
+	}, http.MethodGet)
+	request := httptest.NewRequest("GET", "/tracing-test/Mary", nil)
+	request.Header.Set(tracing.TRACEPARENT_HEADER, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	httpserver.TracingMiddleware(router)(router).ServeHTTP(httptest.NewRecorder(), request.WithContext(logging.ContextWithRequestID(context.Background(), "order-42")))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("want 3 spans, got %d\n", len(spans))
+	}
+	retrieve, remove, server := spans[0], spans[1], spans[2]
+	if server.Name != "GET /tracing-test/" || server.SpanKind != trace.SpanKindServer || server.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected server span %+v\n", server)
+	}
+	if server.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Status.Code != codes.Error || !hasAttribute(server, attribute.String("request.id", "order-42")) {
+		t.Fatalf("want the server span in the trace of the caller, failed and with the request ID, got %+v\n", server)
+	}
+	for _, child := range []tracetest.SpanStub{retrieve, remove} {
+		if child.SpanContext.TraceID() != server.SpanContext.TraceID() || child.Parent.SpanID() != server.SpanContext.SpanID() || child.SpanKind != trace.SpanKindClient {
+			t.Fatalf("want %s to be a client child of the server span, got %+v\n", child.Name, child)
+		}
+	}
+	if retrieve.Status.Code == codes.Error || remove.Status.Code != codes.Error {
+		t.Fatalf("want only the failed delete to record an error, got %v and %v\n", retrieve.Status, remove.Status)
+	}
+}
+
+func hasAttribute(span tracetest.SpanStub, expected attribute.KeyValue) bool {
+	for _, attribute := range span.Attributes {
+		if attribute == expected {
+			return true
+		}
+	}
+	return false
+}
@@ -2,29 +2,55 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	b64 "encoding/base64"
 	"encoding/json"
-	"fmt"
+	"flag"
+	"net/http"
+	"os"
 	"time"
 
 	"github.com/oracle/oci-go-sdk/v65/common"
 	"github.com/oracle/oci-go-sdk/v65/secrets"
 	"github.com/oracle/oci-go-sdk/v65/streaming"
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/metrics"
+	"go-on-oci-shared/oracledb"
+	"go-on-oci-shared/people"
+	"go-on-oci-shared/schema"
+	"go-on-oci-shared/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	streamDetailsSecretOCID = "ocid1.vaultsecret.oc1.iad.amaaaaaa6sde7caa6m5tuweeu3lbz22lf37y2dsbdojnhz2owmgvqgwwnvka"
+	REQUEST_ID_HEADER       = "X-Request-Id"
+	ENV_KEY_METRICS_PORT    = "METRICS_PORT"
+	DEFAULT_METRICS_PORT    = "2112"
+)
+
+var (
+	messagesConsumed = metrics.NewCounterVec("stream_messages_consumed_total",
+		"Number of messages read from the stream.")
+	messagesFailed = metrics.NewCounterVec("stream_messages_failed_total",
+		"Number of messages that were not persisted, per reason.", "reason")
+	pollErrors = metrics.NewCounterVec("stream_poll_errors_total",
+		"Number of failed requests for messages from the stream.")
+	consumerLag = metrics.NewGaugeVec("stream_consumer_lag_seconds",
+		"Time between publishing and consuming the latest message, per partition.", "partition")
 )
 
 type StreamConnectDetails struct {
-	StreamMessagesEndpoint string `json:streamMessagesEndpoint`
-	StreamOCID             string `json:streamOCID`
+	StreamMessagesEndpoint string `json:"streamMessagesEndpoint"`
+	StreamOCID             string `json:"streamOCID"`
 }
 
 func getStreamConnectDetails() StreamConnectDetails {
 	secretsClient, err := secrets.NewSecretsClientWithConfigurationProvider(common.DefaultConfigProvider())
 	if err != nil {
-		fmt.Printf("failed to get secretsclient : %s", err)
+		logging.Error("failed to get secretsclient", "error", err)
 	}
 	secretReq := secrets.GetSecretBundleRequest{SecretId: common.String(streamDetailsSecretOCID)}
 	secretResponse, _ := secretsClient.GetSecretBundle(context.Background(), secretReq)
@@ -36,18 +62,49 @@ func getStreamConnectDetails() StreamConnectDetails {
 }
 
 func main() {
-	streamConnectDetails := getStreamConnectDetails()
-	streamClient, err := streaming.NewStreamClientWithConfigurationProvider(common.DefaultConfigProvider(), streamConnectDetails.StreamMessagesEndpoint)
+	migrateCommand := flag.String("migrate", "", "run schema migrations and exit: up applies all pending migrations, down rolls back the latest one, status lists applied and pending migrations")
+	flag.Parse()
+	if err := logging.Configure(); err != nil {
+		logging.Error("invalid logging configuration", "error", err)
+		os.Exit(1)
+	}
+	if err := tracing.Configure("from-stream-to-database"); err != nil {
+		logging.Error("invalid tracing configuration", "error", err)
+		os.Exit(1)
+	}
+	defer tracing.Shutdown()
+	_, err := InitializeDatabase()
 	if err != nil {
-		fmt.Printf("failed to create streamClient : %s", err)
+		logging.Error("can't connect to the database", "error", err)
+		os.Exit(1)
 	}
-	database = InitializeDatabase()
 	defer func() {
 		err := database.Close()
 		if err != nil {
-			fmt.Println("Can't close connection: ", err)
+			logging.Error("can't close connection", "error", err)
 		}
 	}()
+	if *migrateCommand != "" {
+		err := schema.RunMigrationCommand(database, *migrateCommand, os.Stdout)
+		if err != nil {
+			logging.Error("schema migration failed", "command", *migrateCommand, "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	err = InitializeSchema(database)
+	if err != nil {
+		// as the HTTP services, which stay unready, the consumer takes no messages for a database that is not in the shape it expects
+		logging.Error("problem in initializing the database schema; not consuming messages", "error", err)
+		os.Exit(1)
+	}
+	oracledb.RegisterPoolMetrics(func() (*sql.DB, error) { return database, nil })
+	serveMetrics()
+	streamConnectDetails := getStreamConnectDetails()
+	streamClient, err := streaming.NewStreamClientWithConfigurationProvider(common.DefaultConfigProvider(), streamConnectDetails.StreamMessagesEndpoint)
+	if err != nil {
+		logging.Error("failed to create streamClient", "error", err)
+	}
 
 	// Type can be CreateGroupCursorDetailsTypeTrimHorizon, CreateGroupCursorDetailsTypeAtTime, CreateGroupCursorDetailsTypeLatest
 	createGroupCursorRequest := streaming.CreateGroupCursorRequest{
@@ -60,7 +117,7 @@ func main() {
 
 	createGroupCursorResponse, err := streamClient.CreateGroupCursor(context.Background(), createGroupCursorRequest)
 	if err != nil {
-		fmt.Println(err)
+		logging.Error("failed to create group cursor", "error", err)
 	}
 	consumeMessagesLoop(streamClient, streamConnectDetails.StreamOCID, *createGroupCursorResponse.Value)
 }
@@ -70,15 +127,20 @@ func consumeMessagesLoop(streamClient streaming.StreamClient, streamOcid string,
 		StreamId: common.String(streamOcid),
 		Cursor:   common.String(cursorValue)}
 	for i := 0; i < 15; i++ {
-		fmt.Println("starting iteration ", i)
+		logging.Debug("starting iteration", "iteration", i)
 		getMessagesFromCursorRequest.Cursor = common.String(cursorValue)
 		// Send the request using the service client
 		getMessagesFromCursorResponse, err := streamClient.GetMessages(context.Background(), getMessagesFromCursorRequest)
 		if err != nil {
-			fmt.Println(err)
+			logging.Error("failed to get messages", "error", err)
+			pollErrors.Inc()
 		}
 		for _, message := range getMessagesFromCursorResponse.Items {
-			fmt.Println("Message consumed with Key : " + string(message.Key) + ", value : " + string(message.Value) + ", Partition " + *message.Partition)
+			logging.Debug("message consumed", "key", string(message.Key), "partition", *message.Partition, "offset", *message.Offset)
+			messagesConsumed.Inc()
+			if message.Timestamp != nil {
+				consumerLag.Set(time.Since(message.Timestamp.Time).Seconds(), *message.Partition)
+			}
 			processPersonMessage(message.Value)
 		}
 		cursorValue = *getMessagesFromCursorResponse.OpcNextCursor
@@ -87,17 +149,63 @@ func consumeMessagesLoop(streamClient streaming.StreamClient, streamOcid string,
 	}
 }
 
+// Person is the message on the stream; Headers carries metadata such as the X-Request-Id and traceparent of the producer,
+// as Streaming messages have no headers of their own
 type Person struct {
-	Name         string `json:"name"`
-	Age          int    `json:"age"`
-	JuicyDetails string `json:"comment"`
+	Name         string            `json:"name"`
+	Age          int               `json:"age"`
+	JuicyDetails string            `json:"comment"`
+	Headers      map[string]string `json:"headers,omitempty"`
 }
 
+// processPersonMessage persists the person in the message, logging under the request ID of the producer (or a new one when the message has none)
+// and tracing as part of the trace of the producer; a message that cannot be persisted is counted as failed and skipped
 func processPersonMessage(message []byte) {
 	var person Person
 	err := json.Unmarshal(message, &person)
 	if err != nil {
-		fmt.Println(err)
+		logging.Error("can't parse person message", "error", err)
+		messagesFailed.Inc("parse")
+		return
+	}
+	ctx := tracing.Extract(context.Background(), propagation.MapCarrier(person.Headers))
+	requestID := person.Headers[REQUEST_ID_HEADER]
+	if requestID == "" {
+		requestID = logging.NewRequestID()
+	}
+	ctx = logging.ContextWithRequestID(ctx, requestID)
+	ctx, span := tracing.Start(ctx, "processPersonMessage", trace.SpanKindConsumer,
+		attribute.String("messaging.system", "oci_streaming"),
+		attribute.String("request.id", requestID))
+	defer span.End()
+	if validationErrors := people.Validate(person.Name, person.Age, person.JuicyDetails); len(validationErrors) > 0 {
+		logging.FromContext(ctx).Warn("skipping person message", "person", person.Name, "validationErrors", validationErrors)
+		messagesFailed.Inc("validation")
+		tracing.RecordError(span, validationErrors)
+		return
+	}
+	if err := PersistPerson(ctx, person); err != nil {
+		logging.FromContext(ctx).Error("can't persist person message", "person", person.Name, "error", err)
+		messagesFailed.Inc("database")
+		tracing.RecordError(span, err)
 	}
-	PersistPerson(person)
+}
+
+// serveMetrics exposes /metrics for Prometheus on METRICS_PORT while the consumer runs
+func serveMetrics() {
+	port, ok := os.LookupEnv(ENV_KEY_METRICS_PORT)
+	if !ok {
+		port = DEFAULT_METRICS_PORT
+	}
+	messagesConsumed.Add(0)
+	pollErrors.Add(0)
+	mux := http.NewServeMux()
+	mux.HandleFunc(metrics.METRICS_PATH, metrics.Handler(metrics.Default()))
+	server := &http.Server{Addr: ":" + port, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		logging.Info("serving metrics", "port", port, "path", metrics.METRICS_PATH)
+		if err := server.ListenAndServe(); err != nil {
+			logging.Error("metrics server stopped", "error", err)
+		}
+	}()
 }
@@ -6,22 +6,18 @@ import (
 	b64 "encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
+	"time"
 
 	"github.com/oracle/oci-go-sdk/v65/common"
 	"github.com/oracle/oci-go-sdk/v65/secrets"
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/oracledb"
+	"go-on-oci-shared/people"
+	"go-on-oci-shared/schema"
+	"go-on-oci-shared/settings"
 )
 
-type DatabaseConnectDetails struct {
-	Service        string `json:service`
-	Username       string `json:username`
-	Server         string `json:server`
-	Port           string `json:port`
-	Password       string `json:password`
-	WalletLocation string `json:walletLocation`
-}
-
 const (
 	autonomousDatabaseConnectDetailsSecretOCID = "ocid1.vaultsecret.oc1.iad.amaaaaaa6sde7caabn37hbdsu7dczk6wpxvr7euq7j5fmti2zkjcpwzlmowq"
 	autonomousDatabaseCwalletSsoSecretOCID     = "ocid1.vaultsecret.oc1.iad.amaaaaaa6sde7caazzhfhfsy2v6tqpr3velezxm4r7ld5alifmggjv3le2cq"
@@ -31,25 +27,38 @@ const (
 
 var database *sql.DB
 
-func InitializeDatabase() *sql.DB {
+// InitializeDatabase connects to the database with the details from the vault; the driver defaults to godror
+// and failed attempts are retried for five minutes, unless the details specify otherwise
+func InitializeDatabase() (*sql.DB, error) {
 	initializeWallet()
 	dbConnectDetails := getDatabaseConnectDetails()
 	dbConnectDetails.WalletLocation = walletLocation
-	db := GetSqlDBWithGoDrOrDriver(dbConnectDetails)
+	if dbConnectDetails.Driver == "" {
+		dbConnectDetails.Driver = oracledb.GODROR_DRIVER
+	}
+	if dbConnectDetails.Retry.Deadline == 0 {
+		dbConnectDetails.Retry.Deadline = settings.Duration(5 * time.Minute)
+	}
+	db, err := oracledb.OpenWithRetry(context.Background(), dbConnectDetails)
 	database = db
-	return database
+	return database, err
 }
 
-func getDatabaseConnectDetails() DatabaseConnectDetails {
+// InitializeSchema brings the schema up to date by applying all pending schema migrations
+func InitializeSchema(db *sql.DB) error {
+	return schema.MigrateUp(db)
+}
+
+func getDatabaseConnectDetails() oracledb.Config {
 	secretsClient, err := secrets.NewSecretsClientWithConfigurationProvider(common.DefaultConfigProvider())
 	if err != nil {
-		fmt.Printf("failed to get secretsclient : %s", err)
+		logging.Error("failed to get secretsclient", "error", err)
 	}
 	secretReq := secrets.GetSecretBundleRequest{SecretId: common.String(autonomousDatabaseConnectDetailsSecretOCID)}
 	secretResponse, _ := secretsClient.GetSecretBundle(context.Background(), secretReq)
 	contentDetails := secretResponse.SecretBundleContent.(secrets.Base64SecretBundleContentDetails)
 	decodedSecretContents, _ := b64.StdEncoding.DecodeString(*contentDetails.Content)
-	var dbCredentials DatabaseConnectDetails
+	var dbCredentials oracledb.Config
 	json.Unmarshal(decodedSecretContents, &dbCredentials)
 	return dbCredentials
 }
@@ -57,7 +66,7 @@ func getDatabaseConnectDetails() DatabaseConnectDetails {
 func initializeWallet() {
 	secretsClient, err := secrets.NewSecretsClientWithConfigurationProvider(common.DefaultConfigProvider())
 	if err != nil {
-		fmt.Printf("failed to get secretsclient : %s", err)
+		logging.Error("failed to get secretsclient", "error", err)
 	}
 	secretReq := secrets.GetSecretBundleRequest{SecretId: common.String(autonomousDatabaseCwalletSsoSecretOCID)}
 	secretResponse, _ := secretsClient.GetSecretBundle(context.Background(), secretReq)
@@ -70,31 +79,30 @@ const (
 	PEOPLE_TABLE_NAME = "PEOPLE"
 )
 
-func PersistPerson(person Person) {
-	ctx := context.Background()
+// PersistPerson merges the person into the PEOPLE table; the log records carry the request ID in ctx. A failed merge is rolled
+// back and returned, so the consumer can count it and move on to the next message.
+func PersistPerson(ctx context.Context, person Person) error {
+	personLogger := logging.FromContext(ctx).With("table", PEOPLE_TABLE_NAME, "person", person.Name)
 	tx, err := database.BeginTx(ctx, nil)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("starting transaction: %w", err)
 	}
-	err = mergePerson(ctx, tx, person)
-	if err != nil {
-		log.Fatal(err)
+	if err = mergePerson(ctx, tx, person); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			personLogger.Warn("can't roll back transaction", "error", rollbackErr)
+		}
+		return fmt.Errorf("merging record: %w", err)
 	}
-	err = tx.Commit()
-	if err != nil {
-		log.Fatal(err)
-	} else {
-		log.Printf("Merged record in table %s for person %s", PEOPLE_TABLE_NAME, person.Name)
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
 	}
+	personLogger.Info("merged record")
+	return nil
 }
 
 func mergePerson(ctx context.Context, tx *sql.Tx, person Person) error {
-	mergeStatement := fmt.Sprintf(
-		`MERGE INTO %s t using (select :name name, :age age, :description description from dual) person
-		ON (t.name = person.name )
-		WHEN MATCHED THEN UPDATE SET age = person.age, description = person.description
-		WHEN NOT MATCHED THEN INSERT (t.name, t.age, t.description) values (person.name, person.age, person.description) `,
-		PEOPLE_TABLE_NAME)
-	_, err := tx.ExecContext(ctx, mergeStatement, person.Name, person.Age, person.JuicyDetails)
+	done := oracledb.TraceStatement(ctx, PEOPLE_TABLE_NAME, "mergePerson", "merge")
+	_, err := tx.ExecContext(ctx, people.MERGE_STATEMENT, person.Name, person.Age, person.JuicyDetails)
+	done(err)
 	return err
 }
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"strings"
+
+	"go-on-oci-shared/oracledb"
+	"go-on-oci-shared/settings"
+)
+
+// Config is the complete configuration of the database client
+type Config struct {
+	Database oracledb.Config `json:"database"`
+}
+
+// configSettings are the environment variables and flags that override the configuration, with those of the shared packages
+var configSettings = oracledb.Settings(func(config *Config) *oracledb.Config { return &config.Database })
+
+func defaultConfig() Config {
+	return Config{
+		Database: oracledb.Config{Driver: oracledb.GO_ORA_DRIVER, Port: oracledb.DEFAULT_DB_PORT},
+	}
+}
+
+// LoadConfig registers the configuration flags with the flag set, parses the arguments and builds the configuration in the
+// layers of settings.Load on top of the defaults. The configuration is not validated; call Validate before using it.
+func LoadConfig(flags *flag.FlagSet, arguments []string) (Config, error) {
+	config := defaultConfig()
+	err := settings.Load(flags, arguments, &config, configSettings)
+	return config, err
+}
+
+// Validate reports all problems with the configuration in a single error
+func (config Config) Validate() error {
+	problems := config.Database.Problems()
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// PrintConfig writes the configuration as JSON, with secrets redacted
+func PrintConfig(config Config, out io.Writer) error {
+	return settings.Print(config, configSettings, out)
+}
@@ -1,33 +1,34 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"flag"
 	"fmt"
-
-	//"os"
+	"log"
+	"os"
 	"time"
-)
-
-var localDB = map[string]string{
-	"service":  "XE",
-	"username": "demo",
-	"server":   "localhost",
-	"port":     "1521",
-	"password": "demo",
-}
 
-var autonomousDB = map[string]string{
-	"service":        "k8j2fvxbaujdcfy_goonocidb_medium.adb.oraclecloud.com",
-	"username":       "demo",
-	"server":         "adb.us-ashburn-1.oraclecloud.com",
-	"port":           "1522",
-	"password":       "thePassword1",
-	"walletLocation": ".",
-}
+	"go-on-oci-shared/oracledb"
+)
 
 func main() {
-	db := GetSqlDBWithPureDriver(autonomousDB)
-	//db := GetSqlDBWithGoDrOrDriver(autonomousDB)
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, with secrets redacted, and exit")
+	config, err := LoadConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Problem in loading the configuration: %s", err)
+	}
+	if *printConfig {
+		PrintConfig(config, os.Stdout)
+		return
+	}
+	if err := config.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %s", err)
+	}
+	db, err := oracledb.OpenWithRetry(context.Background(), config.Database)
+	if err != nil {
+		log.Fatalf("Problem in connecting to the database: %s", err)
+	}
 	defer func() {
 		err := db.Close()
 		if err != nil {
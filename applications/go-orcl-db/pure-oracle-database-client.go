@@ -1,26 +0,0 @@
-package main
-
-import (
-	"database/sql"
-	"fmt"
-	"net/url"
-
-	_ "github.com/sijms/go-ora/v2"
-)
-
-func GetSqlDBWithPureDriver(dbParams map[string]string) *sql.DB {
-	connectionString := "oracle://" + dbParams["username"] + ":" + dbParams["password"] + "@" + dbParams["server"] + ":" + dbParams["port"] + "/" + dbParams["service"]
-	if val, ok := dbParams["walletLocation"]; ok && val != "" {
-		connectionString += "?SSL=enable&SSL Verify=false&WALLET=" + url.QueryEscape(dbParams["walletLocation"])
-	}
-	db, err := sql.Open("oracle", connectionString)
-	if err != nil {
-		panic(fmt.Errorf("error in sql.Open: %w", err))
-	}
-
-	err = db.Ping()
-	if err != nil {
-		panic(fmt.Errorf("error pinging db: %w", err))
-	}
-	return db
-}
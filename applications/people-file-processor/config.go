@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"go-on-oci-shared/httpserver"
+	"go-on-oci-shared/oracledb"
+	"go-on-oci-shared/settings"
+)
+
+const (
+	ENV_KEY_IMPORT_CHUNK_SIZE = "IMPORT_CHUNK_SIZE"
+	ENV_KEY_IMPORT_WORKERS    = "IMPORT_WORKERS"
+)
+
+// Config is the complete configuration of the people-file-processor
+type Config struct {
+	HTTPServerPort      string                      `json:"httpServerPort"`
+	Version             string                      `json:"version"`
+	ShutdownGracePeriod settings.Duration           `json:"shutdownGracePeriod"`
+	HTTP                httpserver.MiddlewareConfig `json:"http"`
+	Database            oracledb.Config             `json:"database"`
+	Import              ImportConfig                `json:"import"`
+}
+
+// configSettings are the environment variables and flags that override the configuration, with those of the shared packages
+var configSettings = slices.Concat(
+	[]settings.Setting[Config]{
+		{Flag: "http-port", EnvKey: ENV_KEY_HTTP_SERVER_PORT, Usage: "port the HTTP server listens on", Field: func(config *Config) interface{} { return &config.HTTPServerPort }},
+		{Flag: "version", EnvKey: ENV_KEY_MYSERVER_VERSION, Usage: "version reported by the server", Field: func(config *Config) interface{} { return &config.Version }},
+		{Flag: "shutdown-grace-period", EnvKey: ENV_KEY_SHUTDOWN_GRACE_PERIOD, Usage: "how long requests in flight get to complete at shutdown, such as 20s", Field: func(config *Config) interface{} { return &config.ShutdownGracePeriod }},
+	},
+	httpserver.MiddlewareSettings(func(config *Config) *httpserver.MiddlewareConfig { return &config.HTTP }),
+	oracledb.Settings(func(config *Config) *oracledb.Config { return &config.Database }),
+	[]settings.Setting[Config]{
+		{Flag: "import-chunk-size", EnvKey: ENV_KEY_IMPORT_CHUNK_SIZE, Usage: "number of persons from a people file merged and committed per transaction", Field: func(config *Config) interface{} { return &config.Import.ChunkSize }},
+		{Flag: "import-workers", EnvKey: ENV_KEY_IMPORT_WORKERS, Usage: "number of import jobs processed at the same time; 0 to leave them to other instances", Field: func(config *Config) interface{} { return &config.Import.Workers }},
+	},
+)
+
+func defaultConfig() Config {
+	return Config{
+		HTTPServerPort:      DEFAULT_HTTP_SERVER_PORT,
+		Version:             "unknown",
+		ShutdownGracePeriod: settings.Duration(httpserver.DEFAULT_SHUTDOWN_GRACE_PERIOD),
+		Database: oracledb.Config{
+			Driver: oracledb.GODROR_DRIVER,
+			Port:   oracledb.DEFAULT_DB_PORT,
+			Pool:   oracledb.PoolConfig{MaxOpenConns: 5, MaxIdleConns: 2, ConnMaxLifetime: settings.Duration(30 * time.Minute), ConnMaxIdleTime: settings.Duration(5 * time.Minute)},
+			Retry:  oracledb.RetryConfig{InitialBackoff: settings.Duration(oracledb.DEFAULT_INITIAL_BACKOFF), MaxBackoff: settings.Duration(oracledb.DEFAULT_MAX_BACKOFF), Deadline: settings.Duration(5 * time.Minute)},
+		},
+		Import: ImportConfig{ChunkSize: DEFAULT_IMPORT_CHUNK_SIZE, Workers: DEFAULT_IMPORT_WORKERS},
+	}
+}
+
+// LoadConfig registers the configuration flags with the flag set, parses the arguments and builds the configuration in the
+// layers of settings.Load on top of the defaults. The configuration is not validated; call Validate before using it.
+func LoadConfig(flags *flag.FlagSet, arguments []string) (Config, error) {
+	config := defaultConfig()
+	err := settings.Load(flags, arguments, &config, configSettings)
+	return config, err
+}
+
+// Validate reports all problems with the configuration in a single error
+func (config Config) Validate() error {
+	var problems []string
+	if !oracledb.ValidPort(config.HTTPServerPort) {
+		problems = append(problems, fmt.Sprintf("httpServerPort %q is not a valid port", config.HTTPServerPort))
+	}
+	if config.ShutdownGracePeriod < 0 {
+		problems = append(problems, "shutdownGracePeriod can not be negative")
+	}
+	if config.Import.ChunkSize <= 0 {
+		problems = append(problems, "import.chunkSize must be positive")
+	}
+	if config.Import.Workers < 0 {
+		problems = append(problems, "import.workers can not be negative")
+	}
+	if config.HTTP.RateLimit < 0 || config.HTTP.RateLimitBurst < 0 || config.HTTP.TrustedProxies < 0 {
+		problems = append(problems, "http.rateLimit, http.rateLimitBurst and http.trustedProxies can not be negative")
+	}
+	problems = append(problems, config.Database.Problems()...)
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// PrintConfig writes the configuration as JSON, with secrets redacted
+func PrintConfig(config Config, out io.Writer) error {
+	return settings.Print(config, configSettings, out)
+}
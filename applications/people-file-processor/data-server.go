@@ -3,137 +3,101 @@ package main
 import (
 	"context"
 	"database/sql"
-	b64 "encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
-)
 
-var autonomousDB = map[string]string{
-	"service":        "k8j2fvxbaujdcfy_goonocidb_medium.adb.oraclecloud.com",
-	"username":       "demo",
-	"server":         "adb.us-ashburn-1.oraclecloud.com",
-	"port":           "1522",
-	"password":       "thePassword1",
-	"walletLocation": ".",
-}
+	"go-on-oci-shared/httpserver"
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/oracledb"
+	"go-on-oci-shared/people"
+	"go-on-oci-shared/schema"
+)
 
+// Person is a record in the PEOPLE table; CreatedAt and UpdatedAt are maintained by the database and ignored on input
 type Person struct {
-	Name         string `json:"name"`
-	Age          int    `json:"age"`
-	JuicyDetails string `json:"comment"`
+	Name         string     `json:"name"`
+	Age          int        `json:"age"`
+	JuicyDetails string     `json:"comment"`
+	CreatedAt    *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt    *time.Time `json:"updatedAt,omitempty"`
 }
 
 const (
 	PEOPLE_TABLE_NAME = "PEOPLE"
 )
 
-var database *sql.DB
+// databaseBootstrap establishes the connection to the database in the background; until it has, database operations fail with oracledb.ErrDatabaseUnavailable
+var databaseBootstrap *oracledb.Bootstrap
 
-func tableExists(db *sql.DB, tableName string) (bool, error) {
-	var tblCount int32
-	err := db.QueryRow("SELECT count(table_name) tbl_count FROM user_tables where table_name = upper(:tablename)", tableName).Scan(&tblCount)
-	exists := tblCount > 0
-	return exists, err
+// InitializeDataServer brings the schema up to date by applying all pending schema migrations
+func InitializeDataServer(db *sql.DB) error {
+	return schema.MigrateUp(db)
 }
 
-func InitializeDataServer(db *sql.DB) error {
-	database = db
-	exists, err := tableExists(db, PEOPLE_TABLE_NAME)
+func persistPerson(ctx context.Context, person Person) error {
+	database, err := databaseBootstrap.Database()
 	if err != nil {
 		return err
 	}
-	if !exists {
-		_, err = db.Exec(createTableStatement)
-		log.Printf("Created table %s in Oracle Database \n", PEOPLE_TABLE_NAME)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func PeopleJSONProcessor(peopleJson []byte) {
-	var persons []Person
-	json.Unmarshal(peopleJson, &persons)
-	nameVals := make([]string, len(persons))
-	ageVals := make([]int, len(persons))
-	descriptionVals := make([]string, len(persons))
-	for i, person := range persons {
-		ageVals[i] = person.Age
-		nameVals[i] = person.Name
-		descriptionVals[i] = person.JuicyDetails
-	}
-	ctx := context.Background()
-
 	tx, err := database.BeginTx(ctx, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-
-	tx.Exec(`MERGE INTO PEOPLE t using (select :name name, :age age, :description description from dual) person
-		ON (t.name = person.name )
-		WHEN MATCHED THEN UPDATE SET age = person.age, description = person.description
-		WHEN NOT MATCHED THEN INSERT (t.name, t.age, t.description) values (person.name, person.age, person.description) `,
-		nameVals, ageVals, descriptionVals)
+	err = mergePerson(ctx, tx, person)
 	if err != nil {
-		log.Fatal(err)
+		rollback(tx)
+		return err
 	}
 	err = tx.Commit()
 	if err != nil {
-		log.Fatal(err)
-	} else {
-		log.Printf("Merged records into table %s for %d persons", PEOPLE_TABLE_NAME, len(persons))
+		return err
 	}
+	logging.FromContext(ctx).Info("merged record", "table", PEOPLE_TABLE_NAME, "person", person.Name)
+	return nil
 }
 
-func persistPerson(person Person) {
-	ctx := context.Background()
-	tx, err := database.BeginTx(ctx, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	err = mergePerson(ctx, tx, person)
-	if err != nil {
-		log.Fatal(err)
-	}
-	err = tx.Commit()
+func rollback(tx *sql.Tx) {
+	err := tx.Rollback()
 	if err != nil {
-		log.Fatal(err)
-	} else {
-		log.Printf("Merged record in table %s for person %s", PEOPLE_TABLE_NAME, person.Name)
+		logging.Error("failed to roll back transaction", "error", err)
 	}
 }
 
-const createTableStatement = "CREATE TABLE PEOPLE ( NAME VARCHAR2(100), AGE NUMBER(3), DESCRIPTION VARCHAR2(1000), CREATION_TIME TIMESTAMP DEFAULT SYSTIMESTAMP)"
-
 func mergePerson(ctx context.Context, tx *sql.Tx, person Person) error {
-	mergeStatement := fmt.Sprintf(
-		`MERGE INTO %s t using (select :name name, :age age, :description description from dual) person
-		ON (t.name = person.name )
-		WHEN MATCHED THEN UPDATE SET age = person.age, description = person.description
-		WHEN NOT MATCHED THEN INSERT (t.name, t.age, t.description) values (person.name, person.age, person.description) `,
-		PEOPLE_TABLE_NAME)
-	_, err := tx.ExecContext(ctx, mergeStatement, person.Name, person.Age, person.JuicyDetails)
+	done := oracledb.TraceStatement(ctx, PEOPLE_TABLE_NAME, "mergePerson", "merge")
+	_, err := tx.ExecContext(ctx, people.MERGE_STATEMENT, person.Name, person.Age, person.JuicyDetails)
+	done(err)
 	return err
 }
 
 func DataHandler(response http.ResponseWriter, request *http.Request) {
-	log.Printf("Handle Request for method %s on path %s", request.Method, request.URL.Path)
 	if request.Method == "GET" {
 		queryNameParameter := request.URL.Query().Get("name")
 		selectStatement := fmt.Sprintf(
-			`select age, creation_time, description from %s where name = :name `,
+			`select age, creation_time, updated_time, description from %s where name = :name `,
 			PEOPLE_TABLE_NAME)
-		var creationTime time.Time
+		var creationTime, updatedTime sql.NullTime
 		var person Person
 		person.Name = queryNameParameter
-		row := database.QueryRow(selectStatement, person.Name)
-		err := row.Scan(&person.Age, &creationTime, &person.JuicyDetails)
+		database, err := databaseBootstrap.Database()
+		if err != nil {
+			httpserver.WriteDatabaseError(response, request, err)
+			return
+		}
+		done := oracledb.TraceStatement(request.Context(), PEOPLE_TABLE_NAME, "retrievePerson", "select")
+		row := database.QueryRowContext(request.Context(), selectStatement, person.Name)
+		err = row.Scan(&person.Age, &creationTime, &updatedTime, &person.JuicyDetails)
+		done(err)
+		person.CreatedAt = nullableTime(creationTime)
+		person.UpdatedAt = nullableTime(updatedTime)
+		if err == sql.ErrNoRows {
+			httpserver.WriteError(response, http.StatusNotFound, "not_found", fmt.Sprintf("No person found with name %s", person.Name))
+			return
+		}
 		if err != nil {
-			http.Error(response, err.Error(), http.StatusBadRequest)
+			httpserver.WriteDatabaseError(response, request, err)
 			return
 		}
 		personJson, _ := json.Marshal(person)
@@ -145,10 +109,18 @@ func DataHandler(response http.ResponseWriter, request *http.Request) {
 		// respond to the client with the error message and a 400 status code.
 		err := json.NewDecoder(request.Body).Decode(&person)
 		if err != nil {
-			http.Error(response, err.Error(), http.StatusBadRequest)
+			httpserver.WriteError(response, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		if validationErrors := people.Validate(person.Name, person.Age, person.JuicyDetails); len(validationErrors) > 0 {
+			httpserver.WriteValidationError(response, people.INVALID_PERSON_MESSAGE, validationErrors)
+			return
+		}
+		err = persistPerson(request.Context(), person)
+		if err != nil {
+			httpserver.WriteDatabaseError(response, request, err)
 			return
 		}
-		persistPerson(person)
 		fmt.Fprint(response, fmt.Sprintf("Persisted %s!", person.Name))
 	}
 	if request.Method == "DELETE" {
@@ -157,35 +129,53 @@ func DataHandler(response http.ResponseWriter, request *http.Request) {
 		// respond to the client with the error message and a 400 status code.
 		err := json.NewDecoder(request.Body).Decode(&person)
 		if err != nil {
-			http.Error(response, err.Error(), http.StatusBadRequest)
+			httpserver.WriteError(response, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		err = unpersistPerson(request.Context(), person.Name)
+		if err != nil {
+			httpserver.WriteDatabaseError(response, request, err)
 			return
 		}
-		unpersistPerson(person.Name)
 		fmt.Fprint(response, fmt.Sprintf("Removed record for %s!", person.Name))
 	}
 
 }
-func unpersistPerson(name string) {
-	ctx := context.Background()
+func unpersistPerson(ctx context.Context, name string) error {
+	database, err := databaseBootstrap.Database()
+	if err != nil {
+		return err
+	}
 	tx, err := database.BeginTx(ctx, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	err = deletePerson(ctx, tx, name)
 	if err != nil {
-		log.Fatal(err)
+		rollback(tx)
+		return err
 	}
 	err = tx.Commit()
 	if err != nil {
-		log.Fatal(err)
-	} else {
-		log.Printf("Delete record from table %s for person %s", PEOPLE_TABLE_NAME, name)
+		return err
+	}
+	logging.FromContext(ctx).Info("deleted record", "table", PEOPLE_TABLE_NAME, "person", name)
+	return nil
+}
+
+func nullableTime(timestamp sql.NullTime) *time.Time {
+	if !timestamp.Valid {
+		return nil
 	}
+	return &timestamp.Time
 }
+
 func deletePerson(ctx context.Context, tx *sql.Tx, name string) error {
 	deleteStatement := fmt.Sprintf(
 		`delete %s where name = :name `,
 		PEOPLE_TABLE_NAME)
+	done := oracledb.TraceStatement(ctx, PEOPLE_TABLE_NAME, "deletePerson", "delete")
 	_, err := tx.ExecContext(ctx, deleteStatement, name)
+	done(err)
 	return err
 }
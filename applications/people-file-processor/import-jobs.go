@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"go-on-oci-shared/httpserver"
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/metrics"
+	"go-on-oci-shared/oracledb"
+	"go-on-oci-shared/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	IMPORT_JOBS_TABLE_NAME = "IMPORT_JOBS"
+	IMPORT_JOBS_PATH       = "/people/imports"
+
+	DEFAULT_IMPORT_WORKERS = 2
+	// IMPORT_JOB_POLL_INTERVAL is how often idle workers look for jobs they were not woken up for, such as jobs enqueued
+	// by another instance or left behind by an instance that stopped
+	IMPORT_JOB_POLL_INTERVAL = 5 * time.Second
+	// IMPORT_JOB_STALE_AFTER is how long a running job may go without progress before it is considered abandoned, by an
+	// instance that crashed, and claimed again
+	IMPORT_JOB_STALE_AFTER  = 10 * time.Minute
+	MAX_IMPORT_JOB_ATTEMPTS = 3
+	// IMPORT_JOB_CLAIM_CANDIDATES is how many of the oldest claimable jobs a worker tries to lock, one by one, when it claims a job
+	IMPORT_JOB_CLAIM_CANDIDATES = 10
+	// MAX_STORED_REJECTIONS limits the rejections kept with a job; the rejected count covers all of them
+	MAX_STORED_REJECTIONS = 100
+	MAX_ERROR_LENGTH      = 4000
+)
+
+// the statuses of an import job: queued until a worker claims it, running while it is imported, then succeeded or failed;
+// skipped when the ledger shows that version of the file was imported before
+const (
+	JOB_QUEUED    = "queued"
+	JOB_RUNNING   = "running"
+	JOB_SUCCEEDED = "succeeded"
+	JOB_FAILED    = "failed"
+	JOB_SKIPPED   = "skipped"
+)
+
+var importJobsFinished = metrics.NewCounterVec("import_jobs_finished_total",
+	"Number of people file import jobs that finished, per status.", "status")
+
+// ImportJobRequest is the body of POST /people/imports; Format and Force are optional, as for GET /people
+type ImportJobRequest struct {
+	ObjectName string `json:"objectName"`
+	BucketName string `json:"bucketName"`
+	Format     string `json:"format"`
+	Force      bool   `json:"force"`
+}
+
+// ImportJob is an import of a people file that is processed in the background; the counts of the embedded ImportResult
+// grow with every committed chunk while it runs, and Progress estimates the percentage of the file read
+type ImportJob struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	RequestID string `json:"requestId,omitempty"`
+	Force     bool   `json:"force"`
+	Attempts  int    `json:"attempts"`
+	ImportResult
+	Size       int64      `json:"size,omitempty"`
+	BytesRead  int64      `json:"bytesRead"`
+	Progress   float64    `json:"progress"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// ErrImportJobNotFound is returned for a job ID that is not in the IMPORT_JOBS table
+var ErrImportJobNotFound = errors.New("import job not found")
+
+// ImportQueue keeps the import jobs in the IMPORT_JOBS table and runs a pool of workers that claim and process them. As the
+// queue is the table, jobs survive restarts and several instances can share the work: a worker claims a job with
+// SELECT FOR UPDATE SKIP LOCKED, so no two workers get the same one. A job that is interrupted by a shutdown is queued
+// again; chunks it committed already are merged once more when it is resumed, which leaves the same rows.
+type ImportQueue struct {
+	bootstrap *oracledb.Bootstrap
+	config    ImportConfig
+	wake      chan struct{}
+	cancel    context.CancelFunc
+	workers   sync.WaitGroup
+}
+
+// StartImportWorkers starts config.Workers workers; with none, the instance only enqueues jobs for other instances
+func StartImportWorkers(bootstrap *oracledb.Bootstrap, config ImportConfig) *ImportQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	queue := &ImportQueue{bootstrap: bootstrap, config: config, wake: make(chan struct{}, config.Workers+1), cancel: cancel}
+	for i := 0; i < config.Workers; i++ {
+		queue.workers.Add(1)
+		go queue.work(ctx)
+	}
+	return queue
+}
+
+// Close stops the workers and waits for them; the jobs they were running are queued again
+func (queue *ImportQueue) Close() error {
+	queue.cancel()
+	queue.workers.Wait()
+	return nil
+}
+
+// Enqueue records a queued job for the request and wakes up a worker for it
+func (queue *ImportQueue) Enqueue(ctx context.Context, jobRequest ImportJobRequest) (ImportJob, error) {
+	job := ImportJob{ID: logging.NewRequestID(), Status: JOB_QUEUED, RequestID: logging.RequestIDFromContext(ctx), Force: jobRequest.Force}
+	job.Object, job.Bucket, job.Format = jobRequest.ObjectName, jobRequest.BucketName, jobRequest.Format
+	database, err := queue.bootstrap.Database()
+	if err != nil {
+		return job, err
+	}
+	insertStatement := fmt.Sprintf(
+		`insert into %s (id, status, object_name, bucket_name, format, request_id, force) values (:id, :status, :objectName, :bucketName, :format, :requestId, :force)`,
+		IMPORT_JOBS_TABLE_NAME)
+	force := 0
+	if job.Force {
+		force = 1
+	}
+	done := oracledb.TraceStatement(ctx, IMPORT_JOBS_TABLE_NAME, "enqueueImportJob", "insert_job")
+	_, err = database.ExecContext(ctx, insertStatement, job.ID, job.Status, job.Object, job.Bucket, job.Format, job.RequestID, force)
+	done(err)
+	if err != nil {
+		return job, err
+	}
+	now := time.Now()
+	job.CreatedAt = &now
+	select {
+	case queue.wake <- struct{}{}:
+	default:
+	}
+	logging.FromContext(ctx).Info("enqueued import job", "job", job.ID, "object", job.Object, "bucket", job.Bucket)
+	return job, nil
+}
+
+// Job returns the job with the ID, or ErrImportJobNotFound
+func (queue *ImportQueue) Job(ctx context.Context, id string) (job ImportJob, err error) {
+	database, err := queue.bootstrap.Database()
+	if err != nil {
+		return job, err
+	}
+	selectStatement := fmt.Sprintf(
+		`select id, status, object_name, bucket_name, format, request_id, force, attempts, size_bytes, bytes_read, parsed, inserted, updated, rejected, chunks,
+		rejections, error_message, created_time, started_time, finished_time from %s where id = :id`,
+		IMPORT_JOBS_TABLE_NAME)
+	var format, requestID, rejections, errorMessage sql.NullString
+	var size sql.NullInt64
+	var force int
+	var createdTime, startedTime, finishedTime sql.NullTime
+	done := oracledb.TraceStatement(ctx, IMPORT_JOBS_TABLE_NAME, "retrieveImportJob", "select_job")
+	err = database.QueryRowContext(ctx, selectStatement, id).Scan(&job.ID, &job.Status, &job.Object, &job.Bucket, &format, &requestID, &force, &job.Attempts,
+		&size, &job.BytesRead, &job.Parsed, &job.Inserted, &job.Updated, &job.Rejected, &job.Chunks, &rejections, &errorMessage,
+		&createdTime, &startedTime, &finishedTime)
+	done(err)
+	if err == sql.ErrNoRows {
+		return job, ErrImportJobNotFound
+	}
+	if err != nil {
+		return job, err
+	}
+	job.Format, job.RequestID, job.Error, job.Size = format.String, requestID.String, errorMessage.String, size.Int64
+	job.Force = force != 0
+	if rejections.Valid {
+		if err := json.Unmarshal([]byte(rejections.String), &job.Rejections); err != nil {
+			logging.FromContext(ctx).Warn("rejections of import job are not valid JSON", "job", job.ID, "error", err)
+		}
+	}
+	job.CreatedAt, job.StartedAt, job.FinishedAt = nullableTime(createdTime), nullableTime(startedTime), nullableTime(finishedTime)
+	switch {
+	case job.Status == JOB_SUCCEEDED || job.Status == JOB_SKIPPED:
+		job.Progress = 100
+	case job.Size > 0:
+		job.Progress = float64(job.BytesRead*1000/job.Size) / 10
+	}
+	return job, nil
+}
+
+func (queue *ImportQueue) work(ctx context.Context) {
+	defer queue.workers.Done()
+	for {
+		for ctx.Err() == nil && queue.processNext(ctx) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-queue.wake:
+		case <-time.After(IMPORT_JOB_POLL_INTERVAL):
+		}
+	}
+}
+
+// processNext claims a job and processes it; it returns false when there was no job to claim
+func (queue *ImportQueue) processNext(ctx context.Context) bool {
+	job, err := queue.claim(ctx)
+	if err != nil {
+		if !errors.Is(err, oracledb.ErrDatabaseUnavailable) && ctx.Err() == nil {
+			logging.Error("failed to claim import job", "error", err)
+		}
+		return false
+	}
+	if job.ID == "" {
+		return false
+	}
+	queue.process(ctx, job)
+	return true
+}
+
+// claim marks the oldest queued job, or a running job that went stale, as running for this worker; the job has no ID
+// when there is none
+func (queue *ImportQueue) claim(ctx context.Context) (job ImportJob, err error) {
+	database, err := queue.bootstrap.Database()
+	if err != nil {
+		return job, err
+	}
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return job, err
+	}
+	// Oracle does not combine a row limit with FOR UPDATE, so the oldest claimable jobs are read without locking them and
+	// locked one at a time by their key, until one is not taken by another worker: the worker never locks more than one job
+	claimable := `(status = :queued or (status = :running and updated_time < systimestamp - numtodsinterval(:staleAfter, 'SECOND')))`
+	candidatesStatement := fmt.Sprintf(`select id from %s where %s order by created_time fetch first :candidates rows only`,
+		IMPORT_JOBS_TABLE_NAME, claimable)
+	lockStatement := fmt.Sprintf(`select id from %s where id = :id and %s for update skip locked`, IMPORT_JOBS_TABLE_NAME, claimable)
+	staleAfter := int(IMPORT_JOB_STALE_AFTER.Seconds())
+	var id string
+	done := oracledb.TraceStatement(ctx, IMPORT_JOBS_TABLE_NAME, "claimImportJob", "claim_job")
+	err = func() error {
+		rows, err := tx.QueryContext(ctx, candidatesStatement, JOB_QUEUED, JOB_RUNNING, staleAfter, IMPORT_JOB_CLAIM_CANDIDATES)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		var candidates []string
+		for rows.Next() {
+			var candidate string
+			if err := rows.Scan(&candidate); err != nil {
+				return err
+			}
+			candidates = append(candidates, candidate)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, candidate := range candidates {
+			err := tx.QueryRowContext(ctx, lockStatement, candidate, JOB_QUEUED, JOB_RUNNING, staleAfter).Scan(&id)
+			if err != sql.ErrNoRows {
+				return err
+			}
+		}
+		return nil
+	}()
+	if err == nil && id != "" {
+		updateStatement := fmt.Sprintf(
+			`update %s set status = :running, attempts = attempts + 1, started_time = nvl(started_time, systimestamp), updated_time = systimestamp where id = :id`,
+			IMPORT_JOBS_TABLE_NAME)
+		_, err = tx.ExecContext(ctx, updateStatement, JOB_RUNNING, id)
+	}
+	done(err)
+	if err != nil {
+		rollback(tx)
+		return job, err
+	}
+	if err = tx.Commit(); err != nil || id == "" {
+		return job, err
+	}
+	return queue.Job(ctx, id)
+}
+
+// process imports the file of the job, recording its progress after every chunk and its outcome at the end
+func (queue *ImportQueue) process(ctx context.Context, job ImportJob) {
+	ctx = logging.ContextWithRequestID(ctx, job.RequestID)
+	ctx, span := tracing.Start(ctx, "ImportJob", trace.SpanKindConsumer,
+		attribute.String("import.job", job.ID),
+		attribute.Int("import.attempt", job.Attempts))
+	defer span.End()
+	jobLogger := logging.FromContext(ctx).With("job", job.ID, "object", job.Object, "bucket", job.Bucket)
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			jobLogger.Error("import job panicked", "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+			job.Error = fmt.Sprintf("internal error: %v", recovered)
+			queue.finish(job, JOB_FAILED)
+		}
+	}()
+	if job.Attempts > MAX_IMPORT_JOB_ATTEMPTS {
+		job.Error = fmt.Sprintf("gave up after %d attempts", MAX_IMPORT_JOB_ATTEMPTS)
+		queue.finish(job, JOB_FAILED)
+		return
+	}
+	jobLogger.Info("starting import job", "attempt", job.Attempts)
+	object, err := OpenObject(ctx, job.Object, job.Bucket, compartmentOCID)
+	if err != nil {
+		if ctx.Err() != nil {
+			queue.requeue(job)
+			return
+		}
+		job.Error = err.Error()
+		tracing.RecordError(span, err)
+		queue.finish(job, JOB_FAILED)
+		return
+	}
+	defer object.Content.Close()
+	job.Size = object.Size
+	job.Format, _ = DetectPeopleFormat(job.Format, object.ContentType, job.Object)
+	content := &countingReader{reader: object.Content}
+	object.Content = ioutil.NopCloser(content)
+	result, err := importObject(ctx, object, job.Format, job.Force, queue.config, job.ID, func(progress ImportResult) {
+		job.ImportResult, job.BytesRead = progress, content.Count()
+		queue.recordProgress(ctx, job)
+	})
+	job.ImportResult, job.BytesRead = result, content.Count()
+	if err != nil && ctx.Err() != nil {
+		jobLogger.Info("import job interrupted by shutdown", "chunks", result.Chunks)
+		queue.requeue(job)
+		return
+	}
+	if err != nil {
+		// the job keeps the message for the client; the driver text, which can reveal statements and tables, only goes to the log
+		jobLogger.Error("import job stopped by an error", "chunks", result.Chunks, "oraCode", oracledb.ErrorCode(err), "error", err)
+		job.Error = importErrorMessage(err)
+		tracing.RecordError(span, err)
+		queue.finish(job, JOB_FAILED)
+		return
+	}
+	if result.Skipped {
+		queue.finish(job, JOB_SKIPPED)
+		return
+	}
+	queue.finish(job, JOB_SUCCEEDED)
+}
+
+// recordProgress stores the counts so far; a failure to do so does not stop the import
+func (queue *ImportQueue) recordProgress(ctx context.Context, job ImportJob) {
+	database, err := queue.bootstrap.Database()
+	if err == nil {
+		updateStatement := fmt.Sprintf(
+			`update %s set format = :format, size_bytes = :sizeBytes, bytes_read = :bytesRead, parsed = :parsed, inserted = :inserted, updated = :updated,
+			rejected = :rejected, chunks = :chunks, updated_time = systimestamp where id = :id`,
+			IMPORT_JOBS_TABLE_NAME)
+		done := oracledb.TraceStatement(ctx, IMPORT_JOBS_TABLE_NAME, "recordImportJobProgress", "update_job")
+		_, err = database.ExecContext(ctx, updateStatement, job.Format, job.Size, job.BytesRead, job.Parsed, job.Inserted, job.Updated, job.Rejected, job.Chunks, job.ID)
+		done(err)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to record progress of import job", "job", job.ID, "error", err)
+	}
+}
+
+// truncateUTF8 shortens text to at most maxBytes bytes (the length of a VARCHAR2 column), without cutting a character in two
+func truncateUTF8(text string, maxBytes int) string {
+	if len(text) <= maxBytes {
+		return text
+	}
+	end := maxBytes
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[:end]
+}
+
+// finish records the outcome of the job; it does so even when the worker is being stopped
+func (queue *ImportQueue) finish(job ImportJob, status string) {
+	ctx := logging.ContextWithRequestID(context.Background(), job.RequestID)
+	if len(job.Rejections) > MAX_STORED_REJECTIONS {
+		job.Rejections = job.Rejections[:MAX_STORED_REJECTIONS]
+	}
+	rejections, _ := json.Marshal(job.Rejections)
+	job.Error = truncateUTF8(job.Error, MAX_ERROR_LENGTH)
+	database, err := queue.bootstrap.Database()
+	if err == nil {
+		updateStatement := fmt.Sprintf(
+			`update %s set status = :status, format = :format, size_bytes = :sizeBytes, bytes_read = :bytesRead, parsed = :parsed, inserted = :inserted,
+			updated = :updated, rejected = :rejected, chunks = :chunks, rejections = :rejections, error_message = :errorMessage,
+			finished_time = systimestamp, updated_time = systimestamp where id = :id`,
+			IMPORT_JOBS_TABLE_NAME)
+		done := oracledb.TraceStatement(ctx, IMPORT_JOBS_TABLE_NAME, "finishImportJob", "update_job")
+		_, err = database.ExecContext(ctx, updateStatement, status, job.Format, job.Size, job.BytesRead, job.Parsed, job.Inserted, job.Updated,
+			job.Rejected, job.Chunks, string(rejections), job.Error, job.ID)
+		done(err)
+	}
+	importJobsFinished.Inc(status)
+	jobLogger := logging.FromContext(ctx).With("job", job.ID, "status", status, "parsed", job.Parsed, "inserted", job.Inserted, "updated", job.Updated, "rejected", job.Rejected)
+	if err != nil {
+		// the job stays running and is claimed again once it is stale
+		jobLogger.Error("failed to record the outcome of import job", "error", err)
+		return
+	}
+	if status == JOB_FAILED {
+		jobLogger.Warn("import job failed", "error", job.Error)
+		return
+	}
+	jobLogger.Info("import job succeeded", "chunks", job.Chunks)
+}
+
+// requeue returns an interrupted job to the queue, so the next worker to come along resumes it
+func (queue *ImportQueue) requeue(job ImportJob) {
+	ctx := logging.ContextWithRequestID(context.Background(), job.RequestID)
+	database, err := queue.bootstrap.Database()
+	if err == nil {
+		updateStatement := fmt.Sprintf(`update %s set status = :queued, updated_time = systimestamp where id = :id and status = :running`, IMPORT_JOBS_TABLE_NAME)
+		done := oracledb.TraceStatement(ctx, IMPORT_JOBS_TABLE_NAME, "requeueImportJob", "update_job")
+		_, err = database.ExecContext(ctx, updateStatement, JOB_QUEUED, job.ID, JOB_RUNNING)
+		done(err)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to queue interrupted import job again; it is claimed again once stale", "job", job.ID, "error", err)
+	}
+}
+
+// countingReader counts the bytes read through it, for reporting the progress of an import
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (counter *countingReader) Read(buffer []byte) (int, error) {
+	n, err := counter.reader.Read(buffer)
+	atomic.AddInt64(&counter.count, int64(n))
+	return n, err
+}
+
+// Count returns the number of bytes read so far
+func (counter *countingReader) Count() int64 {
+	return atomic.LoadInt64(&counter.count)
+}
+
+// ImportJobsHandler enqueues an import of the people file in the JSON body and responds 202 Accepted with the job, which
+// GET on the Location tells the progress of
+func ImportJobsHandler(queue *ImportQueue) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		var jobRequest ImportJobRequest
+		if err := json.NewDecoder(request.Body).Decode(&jobRequest); err != nil {
+			httpserver.WriteError(response, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		if jobRequest.ObjectName == "" || jobRequest.BucketName == "" {
+			httpserver.WriteError(response, http.StatusBadRequest, "invalid_request", "objectName and bucketName are required")
+			return
+		}
+		if _, err := DetectPeopleFormat(jobRequest.Format, "", jobRequest.ObjectName); err != nil {
+			httpserver.WriteError(response, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		job, err := queue.Enqueue(request.Context(), jobRequest)
+		if err != nil {
+			httpserver.WriteDatabaseError(response, request, err)
+			return
+		}
+		response.Header().Set("Location", IMPORT_JOBS_PATH+"/"+job.ID)
+		httpserver.WriteJSON(response, http.StatusAccepted, job)
+	}
+}
+
+// ImportJobHandler responds with the import job whose ID follows /people/imports/ in the path
+func ImportJobHandler(queue *ImportQueue) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		id := strings.TrimPrefix(request.URL.Path, IMPORT_JOBS_PATH+"/")
+		if id == "" || strings.Contains(id, "/") {
+			httpserver.WriteError(response, http.StatusNotFound, "not_found", "No import job in path "+request.URL.Path)
+			return
+		}
+		job, err := queue.Job(request.Context(), id)
+		if errors.Is(err, ErrImportJobNotFound) {
+			httpserver.WriteError(response, http.StatusNotFound, "not_found", fmt.Sprintf("No import job found with id %s", id))
+			return
+		}
+		if err != nil {
+			httpserver.WriteDatabaseError(response, request, err)
+			return
+		}
+		httpserver.WriteJSON(response, http.StatusOK, job)
+	}
+}
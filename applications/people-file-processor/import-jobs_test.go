@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"go-on-oci-shared/httpserver"
+	"go-on-oci-shared/oracledb"
+)
+
+func TestImportJobHandlers(t *testing.T) {
+	// without a database, requests that get past validation fail with 503
+	bootstrap := connectingBootstrap(t)
+	queue := StartImportWorkers(bootstrap, ImportConfig{ChunkSize: 10})
+	defer queue.Close()
+	router := newRouter(bootstrap, ImportConfig{ChunkSize: 10}, queue)
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"POST", "/people/imports", `{"objectName": "people.csv"}`, http.StatusBadRequest, "invalid_request"},
+		{"POST", "/people/imports", `{"objectName": "people.xml", "bucketName": "uploads", "format": "xml"}`, http.StatusBadRequest, "invalid_request"},
+		{"POST", "/people/imports", `[`, http.StatusBadRequest, "invalid_request"},
+		{"POST", "/people/imports", `{"objectName": "people.csv", "bucketName": "uploads"}`, http.StatusServiceUnavailable, "database_unavailable"},
+		{"GET", "/people/imports/", "", http.StatusNotFound, "not_found"},
+		{"GET", "/people/imports/0123456789abcdef0123456789abcdef", "", http.StatusServiceUnavailable, "database_unavailable"},
+	}
+	for _, c := range cases {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(c.method, c.path, strings.NewReader(c.body)))
+		var body httpserver.ErrorResponse
+		json.Unmarshal(response.Body.Bytes(), &body)
+		if response.Code != c.status || body.Code != c.code {
+			t.Fatalf("%s %s %s: want %d %s, got %d %s\n", c.method, c.path, c.body, c.status, c.code, response.Code, response.Body.String())
+		}
+	}
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest("GET", "/people/imports", nil))
+	if response.Code != http.StatusMethodNotAllowed || response.Header().Get("Allow") != "OPTIONS, POST" || !strings.Contains(response.Body.String(), `"method_not_allowed"`) {
+		t.Fatalf("want 405 allowing POST, got %d %s %s\n", response.Code, response.Header().Get("Allow"), response.Body.String())
+	}
+}
+
+func TestImportWorkersStop(t *testing.T) {
+	bootstrap := connectingBootstrap(t)
+	queue := StartImportWorkers(bootstrap, ImportConfig{ChunkSize: 10, Workers: 3})
+	stopped := make(chan struct{})
+	go func() {
+		queue.Close()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("want the workers stopped right away when there is no job")
+	}
+}
+
+func TestCountingReader(t *testing.T) {
+	counter := &countingReader{reader: strings.NewReader("name,age\nMary,42\n")}
+	if _, err := io.Copy(ioutil.Discard, counter); err != nil || counter.Count() != 17 {
+		t.Fatalf("want 17 bytes counted, got %d (%v)\n", counter.Count(), err)
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	cases := []struct {
+		text     string
+		maxBytes int
+		expected string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"truncated", 5, "trunc"},
+		{"Zoë", 3, "Zo"},
+		{"Zoë", 4, "Zoë"},
+		{"日本", 5, "日"},
+		{"日本", 2, ""},
+	}
+
+	for _, c := range cases {
+		if truncated := truncateUTF8(c.text, c.maxBytes); truncated != c.expected || !utf8.ValidString(truncated) {
+			t.Fatalf("%q to %d bytes: want %q, got %q\n", c.text, c.maxBytes, c.expected, truncated)
+		}
+	}
+}
+
+// connectingBootstrap returns a bootstrap that keeps connecting until the test ends, so the database stays unavailable
+func connectingBootstrap(t *testing.T) *oracledb.Bootstrap {
+	bootstrap := oracledb.StartBootstrapWith(context.Background(), func(ctx context.Context) (*sql.DB, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, nil)
+	t.Cleanup(func() { bootstrap.Close() })
+	return bootstrap
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-on-oci-shared/httpserver"
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/oracledb"
+)
+
+const (
+	IMPORT_LEDGER_TABLE_NAME = "IMPORT_LEDGER"
+	IMPORT_LEDGER_PATH       = "/people/ledger"
+
+	DEFAULT_LEDGER_LIMIT = 100
+	MAX_LEDGER_LIMIT     = 1000
+)
+
+// LedgerEntry records the import of a version of a people file; Imports counts how often it was imported, more than once
+// when the import was forced. The counts are those of the latest import.
+type LedgerEntry struct {
+	Bucket     string     `json:"bucket"`
+	Object     string     `json:"object"`
+	ETag       string     `json:"etag"`
+	MD5        string     `json:"md5,omitempty"`
+	VersionID  string     `json:"versionId,omitempty"`
+	Size       int64      `json:"size"`
+	Format     string     `json:"format,omitempty"`
+	Parsed     int        `json:"parsed"`
+	Inserted   int        `json:"inserted"`
+	Updated    int        `json:"updated"`
+	Rejected   int        `json:"rejected"`
+	Imports    int        `json:"imports"`
+	JobID      string     `json:"jobId,omitempty"`
+	RequestID  string     `json:"requestId,omitempty"`
+	FirstAt    *time.Time `json:"firstImportedAt,omitempty"`
+	ImportedAt *time.Time `json:"importedAt,omitempty"`
+}
+
+const selectLedgerColumns = `select bucket_name, object_name, etag, md5, version_id, size_bytes, format, parsed, inserted, updated, rejected, imports,
+	job_id, request_id, first_imported_time, imported_time from ` + IMPORT_LEDGER_TABLE_NAME
+
+// importObject imports the people file unless the ledger shows that this version of it, by ETag or by MD5 of its content,
+// was imported before and force is false; the result is then Skipped and names the earlier import. Once the file is
+// imported without error, the ledger records it. Two imports of the same version that run at the same time both go ahead:
+// that is harmless, as merging the same persons twice leaves the same rows.
+func importObject(ctx context.Context, object StoredObject, format string, force bool, config ImportConfig, jobID string, progress func(result ImportResult)) (ImportResult, error) {
+	result := ImportResult{Object: object.Name, Bucket: object.Bucket, Format: format}
+	database, err := databaseBootstrap.Database()
+	if err != nil {
+		return result, err
+	}
+	if !force {
+		entry, found, err := findImport(ctx, database, object)
+		if err != nil {
+			return result, err
+		}
+		if found {
+			logging.FromContext(ctx).Info("skipping file imported before", "object", object.Name, "bucket", object.Bucket, "etag", object.ETag, "importedAt", entry.ImportedAt)
+			result.Skipped, result.PreviousImport = true, &entry
+			return result, nil
+		}
+	}
+	result, err = PeopleFileProcessor(ctx, object.Content, format, config, progress)
+	result.Object, result.Bucket = object.Name, object.Bucket
+	if err != nil {
+		return result, err
+	}
+	if err := recordImport(ctx, database, object, result, jobID); err != nil {
+		// the persons are in; the next import of this version merely merges them again
+		logging.FromContext(ctx).Error("failed to record import in the ledger", "object", object.Name, "bucket", object.Bucket, "error", err)
+	}
+	return result, nil
+}
+
+// findImport returns the latest ledger entry for the object with the same ETag or MD5; objects without ETag are never found
+func findImport(ctx context.Context, database *sql.DB, object StoredObject) (entry LedgerEntry, found bool, err error) {
+	if object.ETag == "" {
+		return entry, false, nil
+	}
+	done := oracledb.TraceStatement(ctx, IMPORT_LEDGER_TABLE_NAME, "findImport", "select_ledger")
+	defer func() { done(err) }()
+	rows, err := database.QueryContext(ctx, selectLedgerColumns+` where bucket_name = :bucket and object_name = :object and (etag = :etag or md5 = :md5)
+		order by imported_time desc`, object.Bucket, object.Name, object.ETag, object.MD5)
+	if err != nil {
+		return entry, false, err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		entry, err = scanLedgerEntry(rows)
+		return entry, err == nil, err
+	}
+	return entry, false, rows.Err()
+}
+
+// recordImport adds the version of the object to the ledger, or counts another import of it when it was forced
+func recordImport(ctx context.Context, database *sql.DB, object StoredObject, result ImportResult, jobID string) error {
+	if object.ETag == "" {
+		return nil
+	}
+	mergeStatement := fmt.Sprintf(
+		`MERGE INTO %s l using (select :bucket bucket_name, :object object_name, :etag etag, :md5 md5, :versionId version_id, :sizeBytes size_bytes,
+		:format format, :parsed parsed, :inserted inserted, :updated updated, :rejected rejected, :jobId job_id, :requestId request_id from dual) imported
+		ON (l.bucket_name = imported.bucket_name and l.object_name = imported.object_name and l.etag = imported.etag)
+		WHEN MATCHED THEN UPDATE SET md5 = imported.md5, version_id = imported.version_id, size_bytes = imported.size_bytes, format = imported.format,
+			parsed = imported.parsed, inserted = imported.inserted, updated = imported.updated, rejected = imported.rejected, imports = l.imports + 1,
+			job_id = imported.job_id, request_id = imported.request_id, imported_time = systimestamp
+		WHEN NOT MATCHED THEN INSERT (l.bucket_name, l.object_name, l.etag, l.md5, l.version_id, l.size_bytes, l.format, l.parsed, l.inserted, l.updated,
+			l.rejected, l.job_id, l.request_id) values (imported.bucket_name, imported.object_name, imported.etag, imported.md5, imported.version_id,
+			imported.size_bytes, imported.format, imported.parsed, imported.inserted, imported.updated, imported.rejected, imported.job_id, imported.request_id)`,
+		IMPORT_LEDGER_TABLE_NAME)
+	done := oracledb.TraceStatement(ctx, IMPORT_LEDGER_TABLE_NAME, "recordImport", "merge_ledger")
+	_, err := database.ExecContext(ctx, mergeStatement, object.Bucket, object.Name, object.ETag, object.MD5, object.VersionID, object.Size,
+		result.Format, result.Parsed, result.Inserted, result.Updated, result.Rejected, jobID, logging.RequestIDFromContext(ctx))
+	done(err)
+	return err
+}
+
+// listImports returns the latest ledger entries, most recent first, for the bucket and object when they are not empty
+func listImports(ctx context.Context, database *sql.DB, bucketName string, objectName string, limit int) (entries []LedgerEntry, err error) {
+	var conditions []string
+	var arguments []interface{}
+	if bucketName != "" {
+		conditions = append(conditions, fmt.Sprintf("bucket_name = :%d", len(arguments)+1))
+		arguments = append(arguments, bucketName)
+	}
+	if objectName != "" {
+		conditions = append(conditions, fmt.Sprintf("object_name = :%d", len(arguments)+1))
+		arguments = append(arguments, objectName)
+	}
+	selectStatement := selectLedgerColumns
+	if len(conditions) > 0 {
+		selectStatement += " where " + strings.Join(conditions, " and ")
+	}
+	selectStatement += fmt.Sprintf(" order by imported_time desc fetch first %d rows only", limit)
+	done := oracledb.TraceStatement(ctx, IMPORT_LEDGER_TABLE_NAME, "listImports", "select_ledger")
+	defer func() { done(err) }()
+	rows, err := database.QueryContext(ctx, selectStatement, arguments...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries = []LedgerEntry{}
+	for rows.Next() {
+		entry, err := scanLedgerEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func scanLedgerEntry(rows *sql.Rows) (entry LedgerEntry, err error) {
+	var md5, versionID, format, jobID, requestID sql.NullString
+	var size sql.NullInt64
+	var firstImportedTime, importedTime sql.NullTime
+	err = rows.Scan(&entry.Bucket, &entry.Object, &entry.ETag, &md5, &versionID, &size, &format, &entry.Parsed, &entry.Inserted, &entry.Updated,
+		&entry.Rejected, &entry.Imports, &jobID, &requestID, &firstImportedTime, &importedTime)
+	entry.MD5, entry.VersionID, entry.Format, entry.JobID, entry.RequestID = md5.String, versionID.String, format.String, jobID.String, requestID.String
+	entry.Size = size.Int64
+	entry.FirstAt, entry.ImportedAt = nullableTime(firstImportedTime), nullableTime(importedTime)
+	return entry, err
+}
+
+// LedgerHandler lists the imported versions of people files, most recent first; the query parameters bucketName and
+// objectName narrow the list down and limit sets its length
+func LedgerHandler(response http.ResponseWriter, request *http.Request) {
+	queryParameters := request.URL.Query()
+	limit := DEFAULT_LEDGER_LIMIT
+	if text := queryParameters.Get("limit"); text != "" {
+		var err error
+		limit, err = strconv.Atoi(text)
+		if err != nil || limit <= 0 || limit > MAX_LEDGER_LIMIT {
+			httpserver.WriteError(response, http.StatusBadRequest, "invalid_request", fmt.Sprintf("limit must be a number from 1 to %d", MAX_LEDGER_LIMIT))
+			return
+		}
+	}
+	database, err := databaseBootstrap.Database()
+	if err != nil {
+		httpserver.WriteDatabaseError(response, request, err)
+		return
+	}
+	entries, err := listImports(request.Context(), database, queryParameters.Get("bucketName"), queryParameters.Get("objectName"), limit)
+	if err != nil {
+		httpserver.WriteDatabaseError(response, request, err)
+		return
+	}
+	httpserver.WriteJSON(response, http.StatusOK, entries)
+}
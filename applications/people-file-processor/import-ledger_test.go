@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"go-on-oci-shared/httpserver"
+)
+
+func TestLedgerAndForceParameters(t *testing.T) {
+	defaultBootstrap := databaseBootstrap
+	databaseBootstrap = connectingBootstrap(t)
+	defer func() { databaseBootstrap = defaultBootstrap }()
+	router := newRouter(databaseBootstrap, ImportConfig{ChunkSize: 10}, StartImportWorkers(databaseBootstrap, ImportConfig{}))
+
+	cases := []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{"/people/ledger?limit=0", http.StatusBadRequest, "invalid_request"},
+		{"/people/ledger?limit=all", http.StatusBadRequest, "invalid_request"},
+		{"/people/ledger?bucketName=uploads&limit=10", http.StatusServiceUnavailable, "database_unavailable"},
+		{"/people?objectName=people.csv&bucketName=uploads&force=please", http.StatusBadRequest, "invalid_request"},
+	}
+	for _, c := range cases {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest("GET", c.path, nil))
+		var body httpserver.ErrorResponse
+		json.Unmarshal(response.Body.Bytes(), &body)
+		if response.Code != c.status || body.Code != c.code {
+			t.Fatalf("%s: want %d %s, got %d %s\n", c.path, c.status, c.code, response.Code, response.Body.String())
+		}
+	}
+}
+
+func TestSkippedImportResult(t *testing.T) {
+	result := ImportResult{Object: "people.csv", Bucket: "uploads", Skipped: true, PreviousImport: &LedgerEntry{Bucket: "uploads", Object: "people.csv", ETag: "etag-1", Imports: 1}}
+	content, _ := json.Marshal(result)
+	var decoded map[string]interface{}
+	json.Unmarshal(content, &decoded)
+	previous, _ := decoded["previousImport"].(map[string]interface{})
+	if decoded["skipped"] != true || previous["etag"] != "etag-1" {
+		t.Fatalf("want the skipped result to name the earlier import, got %s\n", content)
+	}
+	content, _ = json.Marshal(ImportResult{Parsed: 1})
+	var imported map[string]interface{}
+	json.Unmarshal(content, &imported)
+	if _, ok := imported["previousImport"]; ok {
+		t.Fatalf("want no previousImport for an import that went ahead, got %s\n", content)
+	}
+}
+
+// oracleReservedWords are the words Oracle Database does not accept as names, bind variables included (ORA-01745)
+var oracleReservedWords = strings.Fields(`ACCESS ADD ALL ALTER AND ANY AS ASC AUDIT BETWEEN BY CHAR CHECK CLUSTER COLUMN COMMENT COMPRESS
+	CONNECT CREATE CURRENT DATE DECIMAL DEFAULT DELETE DESC DISTINCT DROP ELSE EXCLUSIVE EXISTS FILE FLOAT FOR FROM GRANT GROUP HAVING
+	IDENTIFIED IMMEDIATE IN INCREMENT INDEX INITIAL INSERT INTEGER INTERSECT INTO IS LEVEL LIKE LOCK LONG MAXEXTENTS MINUS MLSLABEL MODE
+	MODIFY NOAUDIT NOCOMPRESS NOT NOWAIT NULL NUMBER OF OFFLINE ON ONLINE OPTION OR ORDER PCTFREE PRIOR PRIVILEGES PUBLIC RAW RENAME
+	RESOURCE REVOKE ROW ROWID ROWNUM ROWS SELECT SESSION SET SHARE SIZE SMALLINT START SUCCESSFUL SYNONYM SYSDATE TABLE THEN TO TRIGGER
+	UID UNION UNIQUE UPDATE USER VALIDATE VALUES VARCHAR VARCHAR2 VIEW WHENEVER WHERE WITH`)
+
+var (
+	sqlStatementPattern = regexp.MustCompile(`(?i)\b(select|insert|update|merge|delete)\b`)
+	bindVariablePattern = regexp.MustCompile(`:([A-Za-z]\w*)`)
+)
+
+// TestBindVariablesAreNotReserved checks the bind variables in every SQL statement of the package, as the database only
+// rejects a reserved word as bind name when the statement runs
+func TestBindVariablesAreNotReserved(t *testing.T) {
+	reserved := map[string]bool{}
+	for _, word := range oracleReservedWords {
+		reserved[word] = true
+	}
+	fileSet := token.NewFileSet()
+	packages, err := parser.ParseDir(fileSet, ".", func(file os.FileInfo) bool { return !strings.HasSuffix(file.Name(), "_test.go") }, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements := 0
+	for _, parsedPackage := range packages {
+		ast.Inspect(parsedPackage, func(node ast.Node) bool {
+			literal, ok := node.(*ast.BasicLit)
+			if !ok || literal.Kind != token.STRING || !sqlStatementPattern.MatchString(literal.Value) {
+				return true
+			}
+			statements++
+			for _, match := range bindVariablePattern.FindAllStringSubmatch(literal.Value, -1) {
+				if reserved[strings.ToUpper(match[1])] {
+					t.Errorf("%s: the bind variable :%s is a reserved word\n", fileSet.Position(literal.Pos()), match[1])
+				}
+			}
+			return true
+		})
+	}
+	if statements == 0 {
+		t.Fatal("want the SQL statements of the package found")
+	}
+}
@@ -1,16 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"go-on-oci-shared/httpserver"
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/metrics"
+	"go-on-oci-shared/oracledb"
+	"go-on-oci-shared/schema"
+	"go-on-oci-shared/tracing"
 )
 
 const (
-	DEFAULT_HTTP_SERVER_PORT = "8080"
-	ENV_KEY_HTTP_SERVER_PORT = "HTTP_SERVER_PORT"
-	ENV_KEY_MYSERVER_VERSION = "VERSION_OF_MYSERVER"
+	DEFAULT_HTTP_SERVER_PORT      = "8080"
+	ENV_KEY_HTTP_SERVER_PORT      = "HTTP_SERVER_PORT"
+	ENV_KEY_MYSERVER_VERSION      = "VERSION_OF_MYSERVER"
+	ENV_KEY_SHUTDOWN_GRACE_PERIOD = "SHUTDOWN_GRACE_PERIOD"
 )
 
 const (
@@ -18,7 +31,6 @@ const (
 	DATA_PATH        = "/data"
 	PEOPLE_PATH      = "/people"
 	STATIC_SITE_PATH = "/site/"
-	ROOT_PATH        = "/"
 	compartmentOCID  = "ocid1.compartment.oc1..aaaaaaaaqb4vxvxuho5h7eewd3fl6dmlh4xg5qaqmtlcmzjtpxszfc7nzbyq" // replace with the OCID of the go-on-oci compartment
 
 )
@@ -27,84 +39,165 @@ func ComposeGreeting(name string) string {
 	nameToGreet := "Stranger"
 	if len(name) > 0 {
 		nameToGreet = name
-		log.Printf(" --  query parameter name is set to %s", name)
+		logging.Debug("query parameter name is set", "name", name)
 	}
 	return fmt.Sprintf("Hello %s!", nameToGreet)
 }
 
-func PeopleHandler(response http.ResponseWriter, request *http.Request) {
-	log.Printf("Handle PeopleHandle Request for method %s on path %s", request.Method, request.URL.Path)
-	if request.Method != "GET" {
-		http.Error(response, "Method is not supported unfortunately. ", http.StatusNotFound)
-		return
-	}
-	queryParameters := request.URL.Query()
-	log.Printf("Queryparams %s", queryParameters)
-	objectName := queryParameters.Get("objectName")
+// PeopleHandler imports the people file objectName in bucket bucketName, streaming it from Object Storage into the database,
+// and responds with the ImportResult as JSON; POST /people/imports imports large files in the background instead. The file
+// is JSON, NDJSON or CSV, optionally gzip compressed: the format query parameter says which, or else the content type or
+// extension of the object. A version of the file that was imported before is skipped, unless force is true, so
+// retried events from Object Storage do not import the same file again.
+func PeopleHandler(config ImportConfig) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		queryParameters := request.URL.Query()
+		objectName := queryParameters.Get("objectName")
 
-	bucketName := queryParameters.Get("bucketName")
-	log.Printf("Process file %s in bucket %s", objectName, bucketName)
-	peopleJson, err := RetrieveObject(objectName, bucketName, compartmentOCID)
-	if err != nil {
-		log.Printf("Failed to Process file %s in bucket %s because of %s", objectName, bucketName, err)
-		fmt.Fprint(response, fmt.Sprintf("Failed to Process file %s in bucket %s because of %s", objectName, bucketName, err))
+		bucketName := queryParameters.Get("bucketName")
+		if objectName == "" || bucketName == "" {
+			httpserver.WriteError(response, http.StatusBadRequest, "invalid_request", "Query parameters objectName and bucketName are required")
+			return
+		}
+		formatParameter := queryParameters.Get("format")
+		if _, err := DetectPeopleFormat(formatParameter, "", objectName); err != nil {
+			httpserver.WriteError(response, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		force := false
+		if text := queryParameters.Get("force"); text != "" {
+			var err error
+			if force, err = strconv.ParseBool(text); err != nil {
+				httpserver.WriteError(response, http.StatusBadRequest, "invalid_request", fmt.Sprintf("force must be true or false, not %q", text))
+				return
+			}
+		}
+		requestLogger := logging.FromContext(request.Context()).With("object", objectName, "bucket", bucketName)
+		requestLogger.Info("process file")
+		object, err := OpenObject(request.Context(), objectName, bucketName, compartmentOCID)
+		if err != nil {
+			requestLogger.Error("failed to process file", "error", err)
+			writeObjectStorageError(response, objectName, bucketName, err)
+			return
+		}
+		defer object.Content.Close()
+		format, _ := DetectPeopleFormat(formatParameter, object.ContentType, objectName)
+		requestLogger.Debug("detected format", "format", format, "contentType", object.ContentType)
+		result, err := importObject(request.Context(), object, format, force, config, "", nil)
+		if errors.Is(err, ErrInvalidPeopleFile) {
+			// the chunks before the point where the file went wrong have been imported; the result says how far it got
+			requestLogger.Warn("file is not a valid people file", "error", err, "chunks", result.Chunks)
+			httpserver.WriteJSON(response, http.StatusUnprocessableEntity, result)
+			return
+		}
+		if err != nil {
+			writeImportDatabaseError(response, request, result, err)
+			return
+		}
+		requestLogger.Info("processed file", "parsed", result.Parsed, "rejected", result.Rejected, "skipped", result.Skipped, "size", object.Size)
+		httpserver.WriteJSON(response, http.StatusOK, result)
+	}
+}
 
-	} else {
-		PeopleJSONProcessor(peopleJson)
-		log.Printf("Processed file %s in bucket %s", objectName, bucketName)
-		fmt.Fprint(response, fmt.Sprintf("Processed file %s in bucket %s", objectName, bucketName))
+// writeImportDatabaseError responds with the status for the database error that stopped an import and the result so far, as
+// the chunks before the error remain committed; the error in the result is the message for the client, not the driver text
+func writeImportDatabaseError(response http.ResponseWriter, request *http.Request, result ImportResult, err error) {
+	class := oracledb.ClassifyError(err)
+	logging.FromContext(request.Context()).Error("import stopped by the database", "code", class.Code, "status", class.Status,
+		"oraCode", oracledb.ErrorCode(err), "chunks", result.Chunks, "error", err)
+	result.Error = class.Message
+	httpserver.WriteJSON(response, class.Status, result)
+}
 
+// writeObjectStorageError reports a failure to read the object: 404 when it does not exist, 502 for other problems
+func writeObjectStorageError(response http.ResponseWriter, objectName string, bucketName string, err error) {
+	var serviceError common.ServiceError
+	if errors.As(err, &serviceError) && serviceError.GetHTTPStatusCode() == http.StatusNotFound {
+		httpserver.WriteError(response, http.StatusNotFound, "object_not_found", fmt.Sprintf("No object %s found in bucket %s", objectName, bucketName))
+		return
 	}
+	httpserver.WriteError(response, http.StatusBadGateway, "object_storage_error", fmt.Sprintf("Failed to retrieve object %s from bucket %s: %s", objectName, bucketName, err))
 }
 
 func greetHandler(response http.ResponseWriter, request *http.Request) {
-	log.Printf("Handle Request for method %s on path %s", request.Method, request.URL.Path)
-	if request.Method != "GET" {
-		http.Error(response, "Method is not supported unfortunately. ", http.StatusNotFound)
-		return
-	}
 	queryName := request.URL.Query().Get("name")
 	fmt.Fprint(response, ComposeGreeting(queryName))
 }
 
 func fallbackHandler(response http.ResponseWriter, request *http.Request) {
-	log.Printf("Warning: Request for unhandled method %s on path %s", request.Method, request.URL.Path)
-	http.Error(response, "404 path/method combination not currently supported. Try /greet or /site", http.StatusNotFound)
+	logging.FromContext(request.Context()).Warn("request for unhandled path", "method", request.Method, "path", request.URL.Path)
+	http.Error(response, "404 path not currently supported. Try /greet or /site", http.StatusNotFound)
+}
+
+// newRouter registers the routes of the people-file-processor
+func newRouter(bootstrap *oracledb.Bootstrap, importConfig ImportConfig, importQueue *ImportQueue) *httpserver.Router {
+	router := httpserver.NewRouter()
+	fileServer := http.FileServer(http.Dir("./website"))
+	router.Handle(STATIC_SITE_PATH, http.StripPrefix("/site/", fileServer), http.MethodGet)
+	router.HandleFunc(GREET_PATH, greetHandler, http.MethodGet)
+	router.HandleFunc(DATA_PATH, DataHandler, http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete)
+	router.HandleFunc(PEOPLE_PATH, PeopleHandler(importConfig), http.MethodGet)
+	router.HandleFunc(IMPORT_JOBS_PATH, ImportJobsHandler(importQueue), http.MethodPost)
+	router.HandleFunc(IMPORT_JOBS_PATH+"/", ImportJobHandler(importQueue), http.MethodGet)
+	router.HandleFunc(IMPORT_LEDGER_PATH, LedgerHandler, http.MethodGet)
+	router.HandleFunc(httpserver.HEALTHZ_PATH, httpserver.HealthHandler(bootstrap), http.MethodGet)
+	router.HandleFunc(httpserver.READYZ_PATH, httpserver.ReadinessHandler(bootstrap), http.MethodGet)
+	router.HandleFunc(metrics.METRICS_PATH, metrics.Handler(metrics.Default()), http.MethodGet)
+	router.NotFound = http.HandlerFunc(fallbackHandler)
+	router.MethodNotAllowed = http.HandlerFunc(httpserver.MethodNotAllowedHandler)
+	return router
 }
 
 func main() {
-	db := GetSqlDBWithGoDrOrDriver(autonomousDB)
-	defer func() {
-		err := db.Close()
-		if err != nil {
-			fmt.Println("Can't close connection: ", err)
-		}
-	}()
-	err := InitializeDataServer(db)
+	migrateCommand := flag.String("migrate", "", "run schema migrations and exit: up applies all pending migrations, down rolls back the latest one, status lists applied and pending migrations")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, with secrets redacted, and exit")
+	if err := logging.Configure(); err != nil {
+		logging.Error("invalid logging configuration", "error", err)
+		os.Exit(1)
+	}
+	if err := tracing.Configure("people-file-processor"); err != nil {
+		logging.Error("invalid tracing configuration", "error", err)
+		os.Exit(1)
+	}
+	config, err := LoadConfig(flag.CommandLine, os.Args[1:])
 	if err != nil {
-		fmt.Println("Problem in initializing the database connection: ", err)
+		logging.Error("problem in loading the configuration", "error", err)
+		os.Exit(1)
 	}
-
-	httpServerPort, ok := os.LookupEnv(ENV_KEY_HTTP_SERVER_PORT)
-	if !ok {
-		httpServerPort = DEFAULT_HTTP_SERVER_PORT
-		log.Printf("Environment Variable %s not set; using default value: %s", ENV_KEY_HTTP_SERVER_PORT, DEFAULT_HTTP_SERVER_PORT)
+	if *printConfig {
+		PrintConfig(config, os.Stdout)
+		return
 	}
-	myserverVersion, ok := os.LookupEnv(ENV_KEY_MYSERVER_VERSION)
-	if !ok {
-		myserverVersion = "unknown"
-		log.Printf("Environment Variable %s not set", ENV_KEY_MYSERVER_VERSION)
+	if err := config.Validate(); err != nil {
+		logging.Error("invalid configuration", "error", err)
+		os.Exit(1)
 	}
-	fileServer := http.FileServer(http.Dir("./website"))
-	http.Handle(STATIC_SITE_PATH, http.StripPrefix("/site/", fileServer))
-	http.HandleFunc(GREET_PATH, greetHandler)
-	http.HandleFunc(DATA_PATH, DataHandler)
-	http.HandleFunc(PEOPLE_PATH, PeopleHandler)
-	http.HandleFunc(ROOT_PATH, fallbackHandler)
+	if *migrateCommand != "" {
+		db, err := oracledb.OpenWithRetry(context.Background(), config.Database)
+		if err != nil {
+			logging.Error("problem in connecting to the database", "error", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		err = schema.RunMigrationCommand(db, *migrateCommand, os.Stdout)
+		if err != nil {
+			logging.Error("schema migration failed", "command", *migrateCommand, "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	// the server starts right away, degraded and not ready, while the bootstrap connects and migrates the schema
+	databaseBootstrap = oracledb.StartBootstrap(context.Background(), config.Database, InitializeDataServer)
+	oracledb.RegisterPoolMetrics(databaseBootstrap.Database)
+	importQueue := StartImportWorkers(databaseBootstrap, config.Import)
 
-	log.Printf("Starting my-server (version %s) listening for requests at port %s\n", myserverVersion, httpServerPort)
-	if err := http.ListenAndServe(":"+httpServerPort, nil); err != nil {
-		log.Printf("serious problem and signing off %s", err)
-		log.Fatal(err)
+	logging.Info("starting my-server", "version", config.Version, "port", config.HTTPServerPort)
+	config.HTTP.PublicPaths = []string{httpserver.HEALTHZ_PATH, httpserver.READYZ_PATH, metrics.METRICS_PATH}
+	handler := httpserver.ServerHandler(newRouter(databaseBootstrap, config.Import, importQueue), config.HTTP)
+	server := httpserver.NewServer(config.HTTPServerPort, handler)
+	if err := httpserver.RunServer(server, time.Duration(config.ShutdownGracePeriod), tracing.Shutdown, databaseBootstrap.Close, CloseObjectStorageClient, importQueue.Close); err != nil {
+		logging.Error("serious problem and signing off", "error", err)
+		os.Exit(1)
 	}
+	logging.Info("my-server stopped")
 }
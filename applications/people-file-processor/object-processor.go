@@ -1,84 +1,152 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"log"
+	"io"
+	"net/http"
+	"sync"
 
 	"github.com/oracle/oci-go-sdk/v65/common"
 	"github.com/oracle/oci-go-sdk/v65/common/auth"
 	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	RUN_WITH_INSTANCE_PRINCIPAL_AUTHENTICATION = false
 )
 
-func RetrieveObject(objectName string, bucketName string, compartmentOCID string) ([]byte, error) {
-	// for running in an environment (such as an OCI Compute Instance or OKE cluster) that inherits instance principal authentication
-	var objectStorageClient objectstorage.ObjectStorageClient
-	if RUN_WITH_INSTANCE_PRINCIPAL_AUTHENTICATION {
-		configurationProvider, err := auth.InstancePrincipalConfigurationProvider()
-		if err != nil {
-			log.Printf("failed to get oci configurationprovider based on instance principal authentication : %s", err)
-			return nil, err
+var (
+	objectStorageClient      objectstorage.ObjectStorageClient
+	objectStorageClientError error
+	objectStorageClientOnce  sync.Once
+)
+
+// getObjectStorageClient creates the Object Storage client on first use; all requests share it and its connections
+func getObjectStorageClient() (objectstorage.ObjectStorageClient, error) {
+	objectStorageClientOnce.Do(func() {
+		// for running in an environment (such as an OCI Compute Instance or OKE cluster) that inherits instance principal authentication
+		if RUN_WITH_INSTANCE_PRINCIPAL_AUTHENTICATION {
+			configurationProvider, err := auth.InstancePrincipalConfigurationProvider()
+			if err != nil {
+				logging.Error("failed to get oci configurationprovider based on instance principal authentication", "error", err)
+				objectStorageClientError = err
+				return
+			}
+			objectStorageClient, objectStorageClientError = objectstorage.NewObjectStorageClientWithConfigurationProvider(configurationProvider)
+		} else {
+			// for running in an environment with ~/.oci/config in place:
+			objectStorageClient, objectStorageClientError = objectstorage.NewObjectStorageClientWithConfigurationProvider(common.DefaultConfigProvider())
 		}
-		objectStorageClient, err = objectstorage.NewObjectStorageClientWithConfigurationProvider(configurationProvider)
-		if err != nil {
-			log.Printf("failed to create ObjectStorageClient : %s", err)
-			return nil, err
+		if objectStorageClientError != nil {
+			logging.Error("failed to create ObjectStorageClient", "error", objectStorageClientError)
 		}
-	} else {
-		// for running in an environment with ~/.oci/config in place:
-		var err error
-		objectStorageClient, err = objectstorage.NewObjectStorageClientWithConfigurationProvider(common.DefaultConfigProvider())
+	})
+	return objectStorageClient, objectStorageClientError
+}
 
-		if err != nil {
-			log.Printf("failed to create ObjectStorageClient : %s", err)
-			return nil, err
-		}
+// CloseObjectStorageClient releases the connections kept open by the Object Storage client, at shutdown
+func CloseObjectStorageClient() error {
+	if httpClient, ok := objectStorageClient.HTTPClient.(*http.Client); ok {
+		httpClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// StoredObject is an object in a bucket that is being read; close Content when done with it. ETag changes with every
+// write of the object, MD5 only with its content; VersionID is set in buckets with versioning enabled.
+type StoredObject struct {
+	Name        string
+	Bucket      string
+	Size        int64
+	ContentType string
+	ETag        string
+	MD5         string
+	VersionID   string
+	Content     io.ReadCloser
+}
+
+// OpenObject starts reading the object from the bucket; the content is streamed from Object Storage as it is read, so objects
+// of any size can be processed. The request ID in ctx is sent along as opc-client-request-id.
+func OpenObject(ctx context.Context, objectName string, bucketName string, compartmentOCID string) (object StoredObject, err error) {
+	ctx, span := tracing.Start(ctx, "OpenObject", trace.SpanKindClient,
+		attribute.String("objectstorage.bucket", bucketName),
+		attribute.String("objectstorage.object", objectName))
+	defer func() {
+		span.SetAttributes(attribute.Int64("object.size", object.Size))
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+	objectStorageClient, err := getObjectStorageClient()
+	if err != nil {
+		return object, err
 	}
-	ctx := context.Background()
-	namespace, cerr := getNamespace(ctx, objectStorageClient)
-	if cerr != nil {
-		log.Printf("failed to get namespace : %s", cerr)
-	} else {
-		log.Printf("Namespace : %s", namespace)
+	namespace, err := getNamespace(ctx, objectStorageClient)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to get namespace", "error", err)
+		return object, err
 	}
+	logging.FromContext(ctx).Debug("retrieved namespace", "namespace", namespace)
 
-	var contentRead []byte
-	contentRead, err := getObject(ctx, objectStorageClient, namespace, bucketName, objectName)
+	object, err = getObject(ctx, objectStorageClient, namespace, bucketName, objectName)
 	if err != nil {
-		log.Printf("failed to get object %s from OCI Object storage : %s", objectName, err)
-		return nil, err
+		logging.FromContext(ctx).Error("failed to get object from OCI Object storage", "object", objectName, "bucket", bucketName, "error", err)
+		return object, err
 	}
-	return contentRead, nil
+	return object, nil
 }
 
 func getNamespace(ctx context.Context, client objectstorage.ObjectStorageClient) (string, error) {
-	request := objectstorage.GetNamespaceRequest{}
+	request := objectstorage.GetNamespaceRequest{OpcClientRequestId: clientRequestID(ctx)}
 	response, err := client.GetNamespace(ctx, request)
 	if err != nil {
-		return *response.Value, fmt.Errorf("failed to retrieve tenancy namespace : %w", err)
+		return "", fmt.Errorf("failed to retrieve tenancy namespace : %w", err)
 	}
 	return *response.Value, nil
 }
 
-func getObject(ctx context.Context, client objectstorage.ObjectStorageClient, namespace string, bucketName string, objectname string) (content []byte, err error) {
+func getObject(ctx context.Context, client objectstorage.ObjectStorageClient, namespace string, bucketName string, objectname string) (StoredObject, error) {
 	request := objectstorage.GetObjectRequest{
 		NamespaceName: &namespace,
 		BucketName:    &bucketName,
 		ObjectName:    &objectname,
+
+		OpcClientRequestId: clientRequestID(ctx),
 	}
 	response, err := client.GetObject(ctx, request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve object : %w", err)
+		return StoredObject{Name: objectname, Bucket: bucketName}, fmt.Errorf("failed to retrieve object : %w", err)
 	}
-	buf := new(bytes.Buffer)
-	_, err = buf.ReadFrom(response.Content)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read content from object on OCI : %w", err)
+	object := StoredObject{Name: objectname, Bucket: bucketName, Content: response.Content}
+	if response.ContentLength != nil {
+		object.Size = *response.ContentLength
+	}
+	if response.ContentType != nil {
+		object.ContentType = *response.ContentType
+	}
+	if response.ETag != nil {
+		object.ETag = *response.ETag
+	}
+	// objects uploaded in parts have no MD5 of their content, only one of the MD5s of their parts
+	if response.ContentMd5 != nil {
+		object.MD5 = *response.ContentMd5
+	} else if response.OpcMultipartMd5 != nil {
+		object.MD5 = *response.OpcMultipartMd5
+	}
+	if response.VersionId != nil {
+		object.VersionID = *response.VersionId
+	}
+	return object, nil
+}
+
+// clientRequestID returns the request ID carried by ctx, for correlating calls to OCI with the request that caused them
+func clientRequestID(ctx context.Context) *string {
+	if requestID := logging.RequestIDFromContext(ctx); requestID != "" {
+		return &requestID
 	}
-	return buf.Bytes(), nil
+	return nil
 }
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// the formats of people files: a JSON array of persons, one JSON person per line, or CSV with a header row
+const (
+	JSON_PEOPLE_FORMAT   = "json"
+	NDJSON_PEOPLE_FORMAT = "ndjson"
+	CSV_PEOPLE_FORMAT    = "csv"
+)
+
+var (
+	utf8ByteOrderMark = []byte{0xEF, 0xBB, 0xBF}
+	gzipMagicNumber   = []byte{0x1F, 0x8B}
+)
+
+// peopleFormatAliases maps the names of the format parameter, media types and file extensions to the formats
+var peopleFormatAliases = map[string]string{
+	"json":                      JSON_PEOPLE_FORMAT,
+	".json":                     JSON_PEOPLE_FORMAT,
+	"application/json":          JSON_PEOPLE_FORMAT,
+	"text/json":                 JSON_PEOPLE_FORMAT,
+	"ndjson":                    NDJSON_PEOPLE_FORMAT,
+	"jsonl":                     NDJSON_PEOPLE_FORMAT,
+	".ndjson":                   NDJSON_PEOPLE_FORMAT,
+	".jsonl":                    NDJSON_PEOPLE_FORMAT,
+	"application/x-ndjson":      NDJSON_PEOPLE_FORMAT,
+	"application/ndjson":        NDJSON_PEOPLE_FORMAT,
+	"application/jsonl":         NDJSON_PEOPLE_FORMAT,
+	"application/json-lines":    NDJSON_PEOPLE_FORMAT,
+	"csv":                       CSV_PEOPLE_FORMAT,
+	"tsv":                       CSV_PEOPLE_FORMAT,
+	".csv":                      CSV_PEOPLE_FORMAT,
+	".tsv":                      CSV_PEOPLE_FORMAT,
+	"text/csv":                  CSV_PEOPLE_FORMAT,
+	"application/csv":           CSV_PEOPLE_FORMAT,
+	"text/tab-separated-values": CSV_PEOPLE_FORMAT,
+	"application/vnd.ms-excel":  CSV_PEOPLE_FORMAT, // what browsers on Windows send for a .csv file saved by Excel
+}
+
+// csvColumns maps the normalized CSV header names to the Person field they fill
+var csvColumns = map[string]string{
+	"name":         "name",
+	"fullname":     "name",
+	"age":          "age",
+	"comment":      "comment",
+	"comments":     "comment",
+	"description":  "comment",
+	"details":      "comment",
+	"juicydetails": "comment",
+}
+
+// ErrUnknownPeopleFormat is returned for a format parameter that names none of the supported formats
+var ErrUnknownPeopleFormat = errors.New("unknown people file format")
+
+// DetectPeopleFormat determines the format of a people file: the format parameter when given, else the content type of the
+// object, else the extension of the object name, ignoring .gz; JSON when none of these tells
+func DetectPeopleFormat(formatParameter string, contentType string, objectName string) (string, error) {
+	if formatParameter != "" {
+		if format, ok := peopleFormatAliases[strings.ToLower(formatParameter)]; ok {
+			return format, nil
+		}
+		return "", fmt.Errorf("%w %q; use %s, %s or %s", ErrUnknownPeopleFormat, formatParameter, JSON_PEOPLE_FORMAT, NDJSON_PEOPLE_FORMAT, CSV_PEOPLE_FORMAT)
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if format, ok := peopleFormatAliases[mediaType]; ok {
+			return format, nil
+		}
+	}
+	extension := strings.ToLower(path.Ext(strings.TrimSuffix(strings.ToLower(objectName), ".gz")))
+	if format, ok := peopleFormatAliases[extension]; ok && extension != "" {
+		return format, nil
+	}
+	return JSON_PEOPLE_FORMAT, nil
+}
+
+// peopleDecoder reads the persons of a people file one at a time. Next returns io.EOF after the last person, an invalid
+// error when only this element could not be read as a person, and an error wrapping ErrInvalidPeopleFile when the file
+// itself is broken and reading cannot go on.
+type peopleDecoder interface {
+	Next() (person Person, invalid error, err error)
+}
+
+// newPeopleDecoder returns the decoder for the format; content that is gzip compressed, whatever the format, is
+// decompressed on the fly, and a UTF-8 byte order mark (as Excel writes) is skipped
+func newPeopleDecoder(content io.Reader, format string) (peopleDecoder, error) {
+	buffered := bufio.NewReader(content)
+	if magic, _ := buffered.Peek(len(gzipMagicNumber)); bytes.Equal(magic, gzipMagicNumber) {
+		decompressed, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPeopleFile, err)
+		}
+		buffered = bufio.NewReader(decompressed)
+	}
+	if bom, _ := buffered.Peek(len(utf8ByteOrderMark)); bytes.Equal(bom, utf8ByteOrderMark) {
+		buffered.Discard(len(utf8ByteOrderMark))
+	}
+	switch format {
+	case NDJSON_PEOPLE_FORMAT:
+		return &ndjsonPeopleDecoder{reader: buffered}, nil
+	case CSV_PEOPLE_FORMAT:
+		return newCSVPeopleDecoder(buffered)
+	default:
+		return &jsonPeopleDecoder{decoder: json.NewDecoder(buffered)}, nil
+	}
+}
+
+// jsonPeopleDecoder reads a JSON array element by element, so one malformed element only rejects that element
+type jsonPeopleDecoder struct {
+	decoder *json.Decoder
+	index   int
+}
+
+func (d *jsonPeopleDecoder) Next() (person Person, invalid error, err error) {
+	if d.index == 0 {
+		token, err := d.decoder.Token()
+		if err != nil {
+			return person, nil, fmt.Errorf("%w: %s", ErrInvalidPeopleFile, err)
+		}
+		if delimiter, ok := token.(json.Delim); !ok || delimiter != '[' {
+			return person, nil, fmt.Errorf("%w: it starts with %v", ErrInvalidPeopleFile, token)
+		}
+	}
+	if !d.decoder.More() {
+		if _, err := d.decoder.Token(); err != nil {
+			return person, nil, fmt.Errorf("%w: %s", ErrInvalidPeopleFile, err)
+		}
+		return person, nil, io.EOF
+	}
+	var element json.RawMessage
+	if err := d.decoder.Decode(&element); err != nil {
+		return person, nil, fmt.Errorf("%w: element %d: %s", ErrInvalidPeopleFile, d.index, err)
+	}
+	d.index++
+	return person, json.Unmarshal(element, &person), nil
+}
+
+// ndjsonPeopleDecoder reads one JSON person per line; blank lines are skipped and a malformed line only rejects that line
+type ndjsonPeopleDecoder struct {
+	reader *bufio.Reader
+}
+
+func (d *ndjsonPeopleDecoder) Next() (person Person, invalid error, err error) {
+	for {
+		line, err := d.reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			return person, json.Unmarshal(line, &person), nil
+		}
+		if err == io.EOF {
+			return person, nil, io.EOF
+		}
+		if err != nil {
+			return person, nil, fmt.Errorf("%w: %s", ErrInvalidPeopleFile, err)
+		}
+	}
+}
+
+// csvPeopleDecoder reads CSV with a header row that names the columns; the columns are matched to the fields of Person by
+// name, regardless of case, spaces, dashes and underscores, and columns it does not know are ignored
+type csvPeopleDecoder struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+// newCSVPeopleDecoder reads the header row; the delimiter is a comma, a semicolon (as Excel uses in locales with a
+// decimal comma) or a tab, whichever occurs most in the header row
+func newCSVPeopleDecoder(content *bufio.Reader) (*csvPeopleDecoder, error) {
+	reader := csv.NewReader(content)
+	reader.Comma = sniffDelimiter(content)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: no CSV header row: %s", ErrInvalidPeopleFile, err)
+	}
+	columns := map[string]int{}
+	for i, column := range header {
+		normalized := strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(column)))
+		if field, ok := csvColumns[normalized]; ok {
+			if _, duplicate := columns[field]; !duplicate {
+				columns[field] = i
+			}
+		}
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("%w: the CSV header row %q has no name column", ErrInvalidPeopleFile, header)
+	}
+	return &csvPeopleDecoder{reader: reader, columns: columns}, nil
+}
+
+func sniffDelimiter(content *bufio.Reader) rune {
+	// a header row longer than the buffer is unlikely; the delimiters counted in the part that fits decide
+	peeked, _ := content.Peek(content.Size())
+	if end := bytes.IndexByte(peeked, '\n'); end >= 0 {
+		peeked = peeked[:end]
+	}
+	delimiter, most := ',', bytes.Count(peeked, []byte{','})
+	for _, candidate := range []rune{';', '\t'} {
+		if count := bytes.Count(peeked, []byte{byte(candidate)}); count > most {
+			delimiter, most = candidate, count
+		}
+	}
+	return delimiter
+}
+
+func (d *csvPeopleDecoder) Next() (person Person, invalid error, err error) {
+	record, err := d.reader.Read()
+	if err == io.EOF {
+		return person, nil, io.EOF
+	}
+	if err != nil {
+		return person, nil, fmt.Errorf("%w: %s", ErrInvalidPeopleFile, err)
+	}
+	field := func(name string) string {
+		if i, ok := d.columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	person.Name = field("name")
+	person.JuicyDetails = field("comment")
+	if age := field("age"); age != "" {
+		person.Age, invalid = strconv.Atoi(age)
+		if invalid != nil {
+			invalid = fmt.Errorf("age %q is not a whole number", age)
+		}
+	}
+	return person, invalid, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDetectPeopleFormat(t *testing.T) {
+	cases := []struct {
+		formatParameter string
+		contentType     string
+		objectName      string
+		want            string
+	}{
+		{"", "", "people.json", JSON_PEOPLE_FORMAT},
+		{"", "", "people.csv", CSV_PEOPLE_FORMAT},
+		{"", "", "exports/people.CSV.gz", CSV_PEOPLE_FORMAT},
+		{"", "", "people.jsonl.gz", NDJSON_PEOPLE_FORMAT},
+		{"", "text/csv; charset=utf-8", "people", CSV_PEOPLE_FORMAT},
+		{"", "application/x-ndjson", "people.json", NDJSON_PEOPLE_FORMAT},
+		{"", "application/octet-stream", "people.ndjson", NDJSON_PEOPLE_FORMAT},
+		{"", "application/gzip", "people.gz", JSON_PEOPLE_FORMAT},
+		{"CSV", "application/json", "people.json", CSV_PEOPLE_FORMAT},
+	}
+	for _, c := range cases {
+		format, err := DetectPeopleFormat(c.formatParameter, c.contentType, c.objectName)
+		if err != nil || format != c.want {
+			t.Fatalf("%+v: want %s, got %s (%v)\n", c, c.want, format, err)
+		}
+	}
+	if _, err := DetectPeopleFormat("xml", "", "people.xml"); !errors.Is(err, ErrUnknownPeopleFormat) {
+		t.Fatalf("want ErrUnknownPeopleFormat for xml, got %v\n", err)
+	}
+}
+
+// decodeAll reads all persons from the content, collecting the invalid elements by index
+func decodeAll(t *testing.T, content io.Reader, format string) ([]Person, map[int]error, error) {
+	decoder, err := newPeopleDecoder(content, format)
+	if err != nil {
+		return nil, nil, err
+	}
+	var persons []Person
+	invalids := map[int]error{}
+	for index := 0; ; index++ {
+		person, invalid, err := decoder.Next()
+		if err == io.EOF {
+			return persons, invalids, nil
+		}
+		if err != nil {
+			return persons, invalids, err
+		}
+		if invalid != nil {
+			invalids[index] = invalid
+			continue
+		}
+		persons = append(persons, person)
+	}
+}
+
+func TestCSVPeopleDecoder(t *testing.T) {
+	// as Excel saves "CSV UTF-8" in a locale with a decimal comma: byte order mark, semicolons, CRLF
+	peopleCSV := "\xEF\xBB\xBFFull Name;AGE;Juicy_Details;Shoe size\r\n" +
+		"Mary;42;\"likes Go; and Oracle\";38\r\n" +
+		"John;twelve;;44\r\n" +
+		"Anna;;\"multi\r\nline\"\r\n"
+	persons, invalids, err := decodeAll(t, strings.NewReader(peopleCSV), CSV_PEOPLE_FORMAT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(persons) != 2 || persons[0] != (Person{Name: "Mary", Age: 42, JuicyDetails: "likes Go; and Oracle"}) || persons[1] != (Person{Name: "Anna", JuicyDetails: "multi\nline"}) {
+		t.Fatalf("unexpected persons %+v\n", persons)
+	}
+	if len(invalids) != 1 || invalids[1] == nil {
+		t.Fatalf("want John rejected for his age, got %v\n", invalids)
+	}
+
+	persons, _, err = decodeAll(t, strings.NewReader("age\tname\n7\tPiet\n"), CSV_PEOPLE_FORMAT)
+	if err != nil || len(persons) != 1 || persons[0] != (Person{Name: "Piet", Age: 7}) {
+		t.Fatalf("want a tab separated file read by column name, got %+v (%v)\n", persons, err)
+	}
+	if _, _, err := decodeAll(t, strings.NewReader("first,last\nMary,Jones\n"), CSV_PEOPLE_FORMAT); !errors.Is(err, ErrInvalidPeopleFile) {
+		t.Fatalf("want ErrInvalidPeopleFile without a name column, got %v\n", err)
+	}
+}
+
+func TestNDJSONPeopleDecoder(t *testing.T) {
+	peopleNDJSON := `{"name": "Mary", "age": 42}
+
+{"name": "John", "age": "twelve"}
+{"name": "Anna"
+{"name": "Piet", "age": 7}`
+	persons, invalids, err := decodeAll(t, strings.NewReader(peopleNDJSON), NDJSON_PEOPLE_FORMAT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(persons) != 2 || persons[0].Name != "Mary" || persons[1].Name != "Piet" || len(invalids) != 2 {
+		t.Fatalf("want Mary and Piet with two lines rejected, got %+v and %v\n", persons, invalids)
+	}
+}
+
+func TestGzipPeopleDecoder(t *testing.T) {
+	for _, format := range []string{JSON_PEOPLE_FORMAT, NDJSON_PEOPLE_FORMAT, CSV_PEOPLE_FORMAT} {
+		content := map[string]string{
+			JSON_PEOPLE_FORMAT:   `[{"name": "Mary", "age": 42}]`,
+			NDJSON_PEOPLE_FORMAT: `{"name": "Mary", "age": 42}`,
+			CSV_PEOPLE_FORMAT:    "name,age\nMary,42\n",
+		}[format]
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		writer.Write([]byte(content))
+		writer.Close()
+		persons, _, err := decodeAll(t, &compressed, format)
+		if err != nil || len(persons) != 1 || persons[0] != (Person{Name: "Mary", Age: 42}) {
+			t.Fatalf("%s: want Mary from the compressed file, got %+v (%v)\n", format, persons, err)
+		}
+	}
+}
+
+func TestDecodePeopleFromCSV(t *testing.T) {
+	var result ImportResult
+	chunks := 0
+	err := decodePeople(context.Background(), mustPeopleDecoder(t, strings.NewReader("name,age\nMary,42\n,7\nJohn,1000\nAnna,7\n"), CSV_PEOPLE_FORMAT), 10, &result, func(persons []Person) error {
+		chunks++
+		return nil
+	})
+	if err != nil || chunks != 1 || result.Parsed != 4 || result.Rejected != 2 || result.Rejections[0].Index != 1 || result.Rejections[1].Name != "John" {
+		t.Fatalf("want 2 of 4 rows imported, got %+v (%v)\n", result, err)
+	}
+}
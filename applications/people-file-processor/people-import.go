@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/oracledb"
+	"go-on-oci-shared/people"
+)
+
+const (
+	// MAX_IN_LIST_SIZE is the number of bind variables Oracle accepts in a single IN list
+	MAX_IN_LIST_SIZE = 1000
+
+	DEFAULT_IMPORT_CHUNK_SIZE = 1000
+)
+
+// ErrInvalidPeopleFile is returned for a file that cannot be read in its format, as opposed to a file with some invalid persons in it
+var ErrInvalidPeopleFile = errors.New("the file is not a valid people file")
+
+// ImportConfig configures the import of people files
+type ImportConfig struct {
+	// ChunkSize is the number of persons merged and committed per transaction
+	ChunkSize int `json:"chunkSize"`
+	// Workers is the number of import jobs processed at the same time
+	Workers int `json:"workers"`
+}
+
+// ImportResult reports what became of the persons in an imported file; Error explains why the import stopped
+// before the end of the file, the chunks committed until then are counted. A Skipped file was not imported as the
+// ledger shows PreviousImport of the same version.
+type ImportResult struct {
+	Object     string           `json:"object,omitempty"`
+	Bucket     string           `json:"bucket,omitempty"`
+	Format     string           `json:"format,omitempty"`
+	Parsed     int              `json:"parsed"`
+	Inserted   int              `json:"inserted"`
+	Updated    int              `json:"updated"`
+	Rejected   int              `json:"rejected"`
+	Chunks     int              `json:"chunks"`
+	Rejections []RejectedPerson `json:"rejections,omitempty"`
+	Error      string           `json:"error,omitempty"`
+
+	Skipped        bool         `json:"skipped,omitempty"`
+	PreviousImport *LedgerEntry `json:"previousImport,omitempty"`
+}
+
+// RejectedPerson is an element of the file that was left out of the import, with the reason why
+type RejectedPerson struct {
+	Index  int                     `json:"index"`
+	Name   string                  `json:"name,omitempty"`
+	Reason string                  `json:"reason"`
+	Fields people.ValidationErrors `json:"fields,omitempty"`
+}
+
+func (result *ImportResult) reject(index int, name string, reason string, fields people.ValidationErrors) {
+	result.Rejected++
+	result.Rejections = append(result.Rejections, RejectedPerson{Index: index, Name: name, Reason: reason, Fields: fields})
+}
+
+// PeopleFileProcessor streams the persons in a people file of the format from content into the PEOPLE table, merging and
+// committing them in chunks of config.ChunkSize, so files of any size are imported with bounded memory. Elements that are
+// not a valid person are rejected and reported in the result; the others are inserted or, when the name exists, updated.
+// The error is ErrInvalidPeopleFile when the file is not (or turns out halfway not to be) of the format, or the database
+// error that rolled back the current chunk; the chunks before it remain committed. progress, when not nil, gets the result
+// so far after every committed chunk.
+func PeopleFileProcessor(ctx context.Context, content io.Reader, format string, config ImportConfig, progress func(result ImportResult)) (ImportResult, error) {
+	result := ImportResult{Format: format}
+	decoder, err := newPeopleDecoder(content, format)
+	if err != nil {
+		result.Error = importErrorMessage(err)
+		return result, err
+	}
+	database, err := databaseBootstrap.Database()
+	if err != nil {
+		return result, err
+	}
+	err = decodePeople(ctx, decoder, config.ChunkSize, &result, func(persons []Person) error {
+		err := importChunk(ctx, database, persons, &result)
+		if err == nil && progress != nil {
+			progress(result)
+		}
+		return err
+	})
+	if err != nil {
+		result.Error = importErrorMessage(err)
+		return result, err
+	}
+	logging.FromContext(ctx).Info("merged records", "table", PEOPLE_TABLE_NAME, "format", format, "inserted", result.Inserted, "updated", result.Updated, "rejected", result.Rejected, "chunks", result.Chunks)
+	return result, nil
+}
+
+// importErrorMessage returns the reason an import stopped as it is reported in ImportResult.Error: what is wrong with the file,
+// or for any other error the message of oracledb.ClassifyError, as the driver text can reveal statements, tables and hosts
+func importErrorMessage(err error) string {
+	if errors.Is(err, ErrInvalidPeopleFile) {
+		return err.Error()
+	}
+	return oracledb.ClassifyError(err).Message
+}
+
+// decodePeople reads the persons from the decoder and hands the ones that pass validation to importChunk in chunks of at
+// most chunkSize
+func decodePeople(ctx context.Context, decoder peopleDecoder, chunkSize int, result *ImportResult, importChunk func(persons []Person) error) error {
+	if chunkSize <= 0 {
+		chunkSize = DEFAULT_IMPORT_CHUNK_SIZE
+	}
+	persons := make([]Person, 0, chunkSize)
+	for index := 0; ; index++ {
+		person, invalid, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		result.Parsed++
+		if invalid != nil {
+			logging.FromContext(ctx).Warn("skipping element in import", "index", index, "error", invalid)
+			result.reject(index, "", invalid.Error(), nil)
+			continue
+		}
+		if validationErrors := people.Validate(person.Name, person.Age, person.JuicyDetails); len(validationErrors) > 0 {
+			logging.FromContext(ctx).Warn("skipping person in import", "index", index, "person", person.Name, "validationErrors", validationErrors)
+			result.reject(index, person.Name, validationErrors.Error(), validationErrors)
+			continue
+		}
+		persons = append(persons, person)
+		if len(persons) == chunkSize {
+			if err := importChunk(persons); err != nil {
+				return err
+			}
+			persons = persons[:0]
+		}
+	}
+	if len(persons) > 0 {
+		return importChunk(persons)
+	}
+	return nil
+}
+
+// importChunk merges the persons in a transaction of their own and counts them in the result once committed
+func importChunk(ctx context.Context, database *sql.DB, persons []Person, result *ImportResult) error {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	existing, err := existingNames(ctx, tx, persons)
+	if err != nil {
+		rollback(tx)
+		return err
+	}
+	err = mergePeople(ctx, tx, persons)
+	if err != nil {
+		rollback(tx)
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	result.countMerges(persons, existing)
+	result.Chunks++
+	logging.FromContext(ctx).Debug("committed chunk", "chunk", result.Chunks, "persons", len(persons))
+	return nil
+}
+
+// countMerges counts the persons as the MERGE treats them, row by row: a name that existed before or occurred earlier
+// in the chunk is updated, any other name is inserted
+func (result *ImportResult) countMerges(persons []Person, existing map[string]bool) {
+	seen := map[string]bool{}
+	for name := range existing {
+		seen[name] = true
+	}
+	for _, person := range persons {
+		if seen[person.Name] {
+			result.Updated++
+		} else {
+			result.Inserted++
+			seen[person.Name] = true
+		}
+	}
+}
+
+// existingNames returns which of the names of the persons are in the PEOPLE table already
+func existingNames(ctx context.Context, tx *sql.Tx, persons []Person) (existing map[string]bool, err error) {
+	existing = map[string]bool{}
+	for start := 0; start < len(persons); start += MAX_IN_LIST_SIZE {
+		end := start + MAX_IN_LIST_SIZE
+		if end > len(persons) {
+			end = len(persons)
+		}
+		placeholders := make([]string, 0, end-start)
+		names := make([]interface{}, 0, end-start)
+		for i, person := range persons[start:end] {
+			placeholders = append(placeholders, fmt.Sprintf(":%d", i+1))
+			names = append(names, person.Name)
+		}
+		selectStatement := fmt.Sprintf(`select name from %s where name in (%s)`, PEOPLE_TABLE_NAME, strings.Join(placeholders, ", "))
+		err = queryNames(ctx, tx, selectStatement, names, existing)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return existing, nil
+}
+
+func queryNames(ctx context.Context, tx *sql.Tx, selectStatement string, names []interface{}, existing map[string]bool) (err error) {
+	done := oracledb.TraceStatement(ctx, PEOPLE_TABLE_NAME, "existingPeople", "select")
+	defer func() { done(err) }()
+	rows, err := tx.QueryContext(ctx, selectStatement, names...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return err
+		}
+		existing[name] = true
+	}
+	return rows.Err()
+}
+
+// mergePeople merges all persons with a single MERGE statement, binding arrays of values
+func mergePeople(ctx context.Context, tx *sql.Tx, persons []Person) error {
+	if len(persons) == 0 {
+		return nil
+	}
+	nameVals := make([]string, len(persons))
+	ageVals := make([]int, len(persons))
+	descriptionVals := make([]string, len(persons))
+	for i, person := range persons {
+		ageVals[i] = person.Age
+		nameVals[i] = person.Name
+		descriptionVals[i] = person.JuicyDetails
+	}
+	done := oracledb.TraceStatement(ctx, PEOPLE_TABLE_NAME, "mergePeople", "merge_batch")
+	_, err := tx.ExecContext(ctx, people.MERGE_STATEMENT, nameVals, ageVals, descriptionVals)
+	done(err)
+	return err
+}
@@ -12,12 +12,18 @@ import (
 	"github.com/oracle/oci-go-sdk/v65/common"
 	"github.com/oracle/oci-go-sdk/v65/common/auth"
 	"github.com/oracle/oci-go-sdk/v65/secrets"
-
 	"github.com/oracle/oci-go-sdk/v65/streaming"
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var streamDetailsSecretOCID string
 
+const REQUEST_ID_HEADER = "X-Request-Id"
+
 type StreamConnectDetails struct {
 	StreamMessagesEndpoint string `json:"streamMessagesEndpoint"`
 	StreamOCID             string `json:"streamOCID"`
@@ -26,7 +32,7 @@ type StreamConnectDetails struct {
 func getStreamConnectDetails(ociConfigurationProvider common.ConfigurationProvider) StreamConnectDetails {
 	secretsClient, err := secrets.NewSecretsClientWithConfigurationProvider(ociConfigurationProvider)
 	if err != nil {
-		fmt.Printf("failed to get secretsclient : %s", err)
+		logging.Error("failed to get secretsclient", "error", err)
 	}
 	secretReq := secrets.GetSecretBundleRequest{SecretId: common.String(streamDetailsSecretOCID)}
 	secretResponse, _ := secretsClient.GetSecretBundle(context.Background(), secretReq)
@@ -35,7 +41,7 @@ func getStreamConnectDetails(ociConfigurationProvider common.ConfigurationProvid
 	var streamConnectDetails StreamConnectDetails
 	err = json.Unmarshal(decodedSecretContents, &streamConnectDetails)
 	if err != nil {
-		fmt.Printf("failed to unmarshal secret : %s", err)
+		logging.Error("failed to unmarshal secret", "error", err)
 	}
 	return streamConnectDetails
 }
@@ -47,29 +53,38 @@ func getFirstNames() []string {
 }
 
 func main() {
-	fmt.Println("Welcome to the Person Producer from Deep Down in the Container - About to publish some person records to the stream")
+	if err := logging.Configure(); err != nil {
+		logging.Error("invalid logging configuration", "error", err)
+		os.Exit(1)
+	}
+	if err := tracing.Configure("person-message-producer"); err != nil {
+		logging.Error("invalid tracing configuration", "error", err)
+		os.Exit(1)
+	}
+	defer tracing.Shutdown()
+	logging.Info("Welcome to the Person Producer from Deep Down in the Container - About to publish some person records to the stream")
 	streamDetailsSecretOCID = os.Getenv("STREAM_DETAILS_SECRET_OCID")
 	if streamDetailsSecretOCID == "" {
-		fmt.Printf("No value set for environment variable STREAM_DETAILS_SECRET_OCID")
+		logging.Error("no value set for environment variable", "variable", "STREAM_DETAILS_SECRET_OCID")
 		panic("No value set for environment variable STREAM_DETAILS_SECRET_OCID")
 	}
 	var ociConfigurationProvider common.ConfigurationProvider
 	var err error
 	if os.Getenv("INSTANCE_PRINCIPAL_AUTHENTICATION") == "NO" {
-		fmt.Println("INSTANCE_PRINCIPAL_AUTHENTICATION == NO; OCI_CONFIG_FILE (only relevant when not doing instance principal authentication):", os.Getenv("OCI_CONFIG_FILE"))
+		logging.Info("INSTANCE_PRINCIPAL_AUTHENTICATION == NO; relying on the OCI config file", "OCI_CONFIG_FILE", os.Getenv("OCI_CONFIG_FILE"))
 		ociConfigurationProvider = common.DefaultConfigProvider()
 	} else {
-		fmt.Println("Relying on Instance Principal Authentication")
+		logging.Info("relying on Instance Principal Authentication")
 		ociConfigurationProvider, err = auth.InstancePrincipalConfigurationProvider()
 		if err != nil {
-			fmt.Printf("failed to create InstancePrincipalConfigurationProvider : %s", err)
+			logging.Error("failed to create InstancePrincipalConfigurationProvider", "error", err)
 			panic(err)
 		}
 	}
 	streamConnectDetails := getStreamConnectDetails(ociConfigurationProvider)
 	streamClient, err := streaming.NewStreamClientWithConfigurationProvider(ociConfigurationProvider, streamConnectDetails.StreamMessagesEndpoint)
 	if err != nil {
-		fmt.Printf("failed to create streamClient : %s", err)
+		logging.Error("failed to create streamClient", "error", err)
 	}
 	firstNames := getFirstNames()
 	for i := 0; i < 5; i++ {
@@ -79,16 +94,30 @@ func main() {
 	}
 }
 
+// Person is the message on the stream; Headers carries metadata such as the X-Request-Id and traceparent, as Streaming messages
+// have no headers of their own
 type Person struct {
-	Name         string `json:"name"`
-	Age          int    `json:"age"`
-	JuicyDetails string `json:"comment"`
+	Name         string            `json:"name"`
+	Age          int               `json:"age"`
+	JuicyDetails string            `json:"comment"`
+	Headers      map[string]string `json:"headers,omitempty"`
 }
 
+// producePersonMessage publishes the person under a new request ID, with which the consumer logs the processing of the record,
+// and in a new trace that the consumer continues
 func producePersonMessage(person Person, streamClient streaming.StreamClient, streamOCID string) {
+	requestID := logging.NewRequestID()
+	ctx, span := tracing.Start(context.Background(), "producePersonMessage", trace.SpanKindProducer,
+		attribute.String("messaging.system", "oci_streaming"),
+		attribute.String("messaging.destination", streamOCID),
+		attribute.String("request.id", requestID))
+	defer span.End()
+	messageLogger := logging.With(logging.REQUEST_ID_KEY, requestID, "person", person.Name)
+	person.Headers = map[string]string{REQUEST_ID_HEADER: requestID}
+	tracing.Inject(ctx, propagation.MapCarrier(person.Headers))
 	personMessage, err := json.Marshal(person)
 	if err != nil {
-		fmt.Println("Producing JSON message failed ", err)
+		messageLogger.Error("producing JSON message failed", "error", err)
 	}
 	putMessagesRequest := streaming.PutMessagesRequest{StreamId: common.String(streamOCID),
 		PutMessagesDetails: streaming.PutMessagesDetails{
@@ -99,12 +128,21 @@ func producePersonMessage(person Person, streamClient streaming.StreamClient, st
 		},
 	}
 	// Send the request using the service client
-	putMsgResp, err := streamClient.PutMessages(context.Background(), putMessagesRequest)
+	putMsgResp, err := streamClient.PutMessages(ctx, putMessagesRequest)
 	if err != nil {
-		fmt.Println("Sad, we ran into an error: ", err)
+		messageLogger.Error("sad, we ran into an error", "error", err)
+		tracing.RecordError(span, err)
+		return
 	}
 
 	// Retrieve value from the response.
-	fmt.Println(putMsgResp)
+	for _, entry := range putMsgResp.Entries {
+		if entry.Error != nil {
+			messageLogger.Error("message was not published", "error", *entry.Error)
+			tracing.RecordError(span, fmt.Errorf("message was not published: %s", *entry.Error))
+			continue
+		}
+		messageLogger.Info("published person message", "partition", *entry.Partition, "offset", *entry.Offset)
+	}
 
 }
@@ -7,22 +7,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-
 	"time"
 
 	"github.com/oracle/oci-go-sdk/v65/common"
 	"github.com/oracle/oci-go-sdk/v65/secrets"
+	"go-on-oci-shared/oracledb"
 )
 
-type DatabaseConnectDetails struct {
-	Service        string `json:service`
-	Username       string `json:username`
-	Server         string `json:server`
-	Port           string `json:port`
-	Password       string `json:password`
-	WalletLocation string `json:walletLocation`
-}
-
 const (
 	autonomousDatabaseConnectDetailsSecretOCID = "ocid1.vaultsecret.oc1.iad.amaaaaaa6sde7caabn37hbdsu7dczk6wpxvr7euq7j5fmti2zkjcpwzlmowq"
 	autonomousDatabaseCwalletSsoSecretOCID     = "ocid1.vaultsecret.oc1.iad.amaaaaaa6sde7caazzhfhfsy2v6tqpr3velezxm4r7ld5alifmggjv3le2cq"
@@ -34,7 +25,14 @@ func main() {
 	initializeWallet()
 	dbConnectDetails := getDatabaseConnectDetails()
 	dbConnectDetails.WalletLocation = walletLocation
-	db := GetSqlDBWithGoDrOrDriver(dbConnectDetails)
+	if dbConnectDetails.Driver == "" {
+		dbConnectDetails.Driver = oracledb.GODROR_DRIVER
+	}
+	db, err := oracledb.Open(dbConnectDetails)
+	if err != nil {
+		fmt.Println("Can't connect to the database: ", err)
+		os.Exit(1)
+	}
 	defer func() {
 		err := db.Close()
 		if err != nil {
@@ -45,7 +43,7 @@ func main() {
 	fmt.Println("DONE")
 }
 
-func getDatabaseConnectDetails() DatabaseConnectDetails {
+func getDatabaseConnectDetails() oracledb.Config {
 	secretsClient, err := secrets.NewSecretsClientWithConfigurationProvider(common.DefaultConfigProvider())
 	if err != nil {
 		fmt.Printf("failed to get secretsclient : %s", err)
@@ -54,7 +52,7 @@ func getDatabaseConnectDetails() DatabaseConnectDetails {
 	secretResponse, _ := secretsClient.GetSecretBundle(context.Background(), secretReq)
 	contentDetails := secretResponse.SecretBundleContent.(secrets.Base64SecretBundleContentDetails)
 	decodedSecretContents, _ := b64.StdEncoding.DecodeString(*contentDetails.Content)
-	var dbCredentials DatabaseConnectDetails
+	var dbCredentials oracledb.Config
 	json.Unmarshal(decodedSecretContents, &dbCredentials)
 	return dbCredentials
 }
@@ -0,0 +1,54 @@
+package httpserver
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"go-on-oci-shared/logging"
+)
+
+// AuthMiddleware requires callers to present one of the tokens (a comma separated list) as bearer token in the Authorization
+// header, except for the public paths; without tokens it does nothing
+func AuthMiddleware(tokens string, publicPaths ...string) Middleware {
+	var validTokens [][]byte
+	for _, token := range strings.Split(tokens, ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			validTokens = append(validTokens, []byte(token))
+		}
+	}
+	public := map[string]bool{}
+	for _, path := range publicPaths {
+		public[path] = true
+	}
+	return func(next http.Handler) http.Handler {
+		if len(validTokens) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			if public[request.URL.Path] || validBearerToken(request.Header.Get("Authorization"), validTokens) {
+				next.ServeHTTP(response, request)
+				return
+			}
+			logging.FromContext(request.Context()).Warn("request without valid token", "method", request.Method, "path", request.URL.Path)
+			response.Header().Set("WWW-Authenticate", `Bearer realm="go-on-oci"`)
+			http.Error(response, "401 a valid bearer token is required", http.StatusUnauthorized)
+		})
+	}
+}
+
+// validBearerToken compares in constant time, so the time to reject a token does not reveal how much of it was right
+func validBearerToken(authorization string, validTokens [][]byte) bool {
+	const prefix = "Bearer "
+	if len(authorization) <= len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return false
+	}
+	token := []byte(strings.TrimSpace(authorization[len(prefix):]))
+	valid := false
+	for _, validToken := range validTokens {
+		if subtle.ConstantTimeCompare(token, validToken) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
@@ -0,0 +1,51 @@
+package httpserver
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	CORS_ALLOWED_METHODS = "GET, HEAD, POST, PUT, PATCH, DELETE"
+	CORS_ALLOWED_HEADERS = "Authorization, Content-Type, If-Match, If-None-Match, X-Request-Id, traceparent"
+	CORS_EXPOSED_HEADERS = "ETag, Location, X-Request-Id"
+	CORS_MAX_AGE_SECONDS = "600"
+)
+
+// CORSMiddleware lets browsers on the allowed origins (a comma separated list, or * for all) call the server and answers
+// their preflight requests; without allowed origins it does nothing
+func CORSMiddleware(allowedOrigins string) Middleware {
+	origins := map[string]bool{}
+	for _, origin := range strings.Split(allowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins[strings.TrimSuffix(origin, "/")] = true
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		if len(origins) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			origin := request.Header.Get("Origin")
+			response.Header().Add("Vary", "Origin")
+			if origin == "" || !(origins["*"] || origins[origin]) {
+				next.ServeHTTP(response, request)
+				return
+			}
+			if origins["*"] {
+				response.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				response.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if request.Method == http.MethodOptions && request.Header.Get("Access-Control-Request-Method") != "" {
+				response.Header().Set("Access-Control-Allow-Methods", CORS_ALLOWED_METHODS)
+				response.Header().Set("Access-Control-Allow-Headers", CORS_ALLOWED_HEADERS)
+				response.Header().Set("Access-Control-Max-Age", CORS_MAX_AGE_SECONDS)
+				response.WriteHeader(http.StatusNoContent)
+				return
+			}
+			response.Header().Set("Access-Control-Expose-Headers", CORS_EXPOSED_HEADERS)
+			next.ServeHTTP(response, request)
+		})
+	}
+}
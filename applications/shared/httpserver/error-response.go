@@ -0,0 +1,62 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/oracledb"
+)
+
+// ErrorResponse is the JSON body returned to clients when a request cannot be handled; the request ID is set on errors the
+// client cannot act on, to find the details in the logs
+type ErrorResponse struct {
+	Status    int               `json:"status"`
+	Code      string            `json:"code"`
+	Message   string            `json:"message"`
+	OraCode   int               `json:"oraCode,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	RequestID string            `json:"requestId,omitempty"`
+}
+
+// WriteJSON responds with the status and value as JSON body
+func WriteJSON(response http.ResponseWriter, status int, value interface{}) {
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(status)
+	err := json.NewEncoder(response).Encode(value)
+	if err != nil {
+		logging.Error("failed to write JSON response", "error", err)
+	}
+}
+
+func WriteError(response http.ResponseWriter, status int, code string, message string) {
+	WriteJSON(response, status, ErrorResponse{Status: status, Code: code, Message: message})
+}
+
+// MethodNotAllowedHandler answers 405 with an ErrorResponse; set it as MethodNotAllowed of a Router, which sets the Allow header
+func MethodNotAllowedHandler(response http.ResponseWriter, request *http.Request) {
+	WriteError(response, http.StatusMethodNotAllowed, "method_not_allowed", "Method is not supported unfortunately.")
+}
+
+// WriteValidationError responds with 422 and the reason each of the invalid fields was rejected
+func WriteValidationError(response http.ResponseWriter, message string, fields map[string]string) {
+	WriteJSON(response, http.StatusUnprocessableEntity, ErrorResponse{
+		Status:  http.StatusUnprocessableEntity,
+		Code:    "validation_failed",
+		Message: message,
+		Fields:  fields,
+	})
+}
+
+// WriteDatabaseError responds with the status and message oracledb.ClassifyError gives the error; the driver text only goes
+// to the log, with the request ID that the response carries on a 500
+func WriteDatabaseError(response http.ResponseWriter, request *http.Request, err error) {
+	class := oracledb.ClassifyError(err)
+	logging.FromContext(request.Context()).Error("database operation failed", "code", class.Code, "status", class.Status,
+		"oraCode", oracledb.ErrorCode(err), "error", err)
+	errorResponse := ErrorResponse{Status: class.Status, Code: class.Code, Message: class.Message, OraCode: class.OraCode}
+	if class.Status == http.StatusInternalServerError {
+		errorResponse.RequestID = logging.RequestIDFromContext(request.Context())
+	}
+	WriteJSON(response, class.Status, errorResponse)
+}
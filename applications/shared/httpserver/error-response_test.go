@@ -0,0 +1,27 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-on-oci-shared/logging"
+)
+
+func TestDatabaseErrorHidesDetails(t *testing.T) {
+	request := httptest.NewRequest("GET", "/people/Mary", nil)
+	request = request.WithContext(logging.ContextWithRequestID(request.Context(), "abc123"))
+	response := httptest.NewRecorder()
+	WriteDatabaseError(response, request, errors.New("ORA-00942: table or view \"DEMO\".\"PEOPLE\" does not exist"))
+
+	var errorResponse ErrorResponse
+	if err := json.Unmarshal(response.Body.Bytes(), &errorResponse); err != nil {
+		t.Fatal(err)
+	}
+	if response.Code != http.StatusInternalServerError || errorResponse.RequestID != "abc123" || strings.Contains(response.Body.String(), "PEOPLE") {
+		t.Fatalf("want a generic 500 with the request ID, got %d %s\n", response.Code, response.Body.String())
+	}
+}
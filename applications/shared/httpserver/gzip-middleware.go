@@ -0,0 +1,119 @@
+package httpserver
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GzipMiddleware compresses textual responses, such as JSON, HTML and plain text, for clients that accept gzip
+func GzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if !acceptsGzip(request.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(response, request)
+			return
+		}
+		writer := &gzipResponseWriter{ResponseWriter: response, head: request.Method == http.MethodHead}
+		next.ServeHTTP(writer, request)
+		// not deferred: after a panic, the status the handler set must stay held back, so that RecoveryMiddleware can still
+		// answer with a 500 instead of aborting a response that seems underway
+		writer.close()
+	})
+}
+
+// acceptsGzip reports whether the Accept-Encoding header lists gzip (or *) without ruling it out with q=0
+func acceptsGzip(acceptEncoding string) bool {
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		parameters := strings.Split(coding, ";")
+		if name := strings.ToLower(strings.TrimSpace(parameters[0])); name != "gzip" && name != "*" {
+			continue
+		}
+		quality := 1.0
+		for _, parameter := range parameters[1:] {
+			if value := strings.TrimSpace(parameter); strings.HasPrefix(value, "q=") {
+				quality, _ = strconv.ParseFloat(value[2:], 64)
+			}
+		}
+		if quality > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter holds back the status until the first write, as only then the content type is known
+// and it can be decided whether to compress
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	head       bool
+	status     int
+	decided    bool
+	gzipWriter *gzip.Writer
+}
+
+func (writer *gzipResponseWriter) WriteHeader(status int) {
+	if writer.status == 0 {
+		writer.status = status
+	}
+}
+
+func (writer *gzipResponseWriter) Write(content []byte) (int, error) {
+	if !writer.decided {
+		writer.decide(content)
+	}
+	if writer.gzipWriter != nil {
+		return writer.gzipWriter.Write(content)
+	}
+	return writer.ResponseWriter.Write(content)
+}
+
+func (writer *gzipResponseWriter) decide(content []byte) {
+	writer.decided = true
+	if writer.status == 0 {
+		writer.status = http.StatusOK
+	}
+	header := writer.Header()
+	if header.Get("Content-Type") == "" && len(content) > 0 {
+		header.Set("Content-Type", http.DetectContentType(content))
+	}
+	header.Add("Vary", "Accept-Encoding")
+	if !writer.head && compressible(writer.status, header) {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", "gzip")
+		writer.gzipWriter = gzip.NewWriter(writer.ResponseWriter)
+	}
+	writer.ResponseWriter.WriteHeader(writer.status)
+}
+
+func (writer *gzipResponseWriter) close() {
+	if !writer.decided {
+		if writer.status == 0 {
+			// the handler wrote nothing at all; leave the response to the server
+			return
+		}
+		writer.decide(nil)
+	}
+	if writer.gzipWriter != nil {
+		writer.gzipWriter.Close()
+	}
+}
+
+// compressible accepts full responses with a body of a textual content type that is not encoded already
+func compressible(status int, header http.Header) bool {
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusPartialContent || status == http.StatusNotModified {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	contentType := strings.TrimSpace(strings.Split(header.Get("Content-Type"), ";")[0])
+	switch {
+	case strings.HasPrefix(contentType, "text/"):
+		return true
+	case contentType == "application/json", contentType == "application/problem+json", contentType == "application/javascript",
+		contentType == "application/xml", contentType == "image/svg+xml":
+		return true
+	}
+	return false
+}
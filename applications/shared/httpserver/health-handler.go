@@ -0,0 +1,97 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go-on-oci-shared/oracledb"
+)
+
+const (
+	HEALTHZ_PATH         = "/healthz"
+	READYZ_PATH          = "/readyz"
+	HEALTH_CHECK_TIMEOUT = 2 * time.Second
+)
+
+// HealthStatus is the response of /healthz and /readyz; Database is left out when the service runs without a database
+type HealthStatus struct {
+	Status   string          `json:"status"`
+	Database *DatabaseHealth `json:"database,omitempty"`
+}
+
+// DatabaseHealth is the outcome of pinging the database together with the state of the connection pool, once there is one
+type DatabaseHealth struct {
+	Status string     `json:"status"`
+	Error  string     `json:"error,omitempty"`
+	Pool   *PoolStats `json:"pool,omitempty"`
+}
+
+// PoolStats is the part of sql.DBStats that shows whether the connection pool is large enough
+type PoolStats struct {
+	MaxOpenConnections int    `json:"maxOpenConnections"`
+	OpenConnections    int    `json:"openConnections"`
+	InUse              int    `json:"inUse"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"waitCount"`
+	WaitDuration       string `json:"waitDuration"`
+	MaxIdleClosed      int64  `json:"maxIdleClosed"`
+	MaxLifetimeClosed  int64  `json:"maxLifetimeClosed"`
+}
+
+// checkDatabase pings the database, giving up after HEALTH_CHECK_TIMEOUT, and collects the statistics of its connection pool;
+// the status is connecting as long as the bootstrap has not established the connection yet
+func checkDatabase(ctx context.Context, bootstrap *oracledb.Bootstrap) *DatabaseHealth {
+	if bootstrap == nil {
+		return nil
+	}
+	db, err := bootstrap.Database()
+	if err != nil {
+		status := "down"
+		if err == oracledb.ErrDatabaseUnavailable {
+			status = "connecting"
+		}
+		return &DatabaseHealth{Status: status, Error: err.Error()}
+	}
+	ctx, cancel := context.WithTimeout(ctx, HEALTH_CHECK_TIMEOUT)
+	defer cancel()
+	health := &DatabaseHealth{Status: "up"}
+	if err := db.PingContext(ctx); err != nil {
+		health.Status = "down"
+		health.Error = err.Error()
+	}
+	stats := db.Stats()
+	health.Pool = &PoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration.String(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+	return health
+}
+
+// HealthHandler answers liveness probes: the service is alive as long as it answers, so the status is 200 even when the database is down;
+// the state of the database is reported nonetheless
+func HealthHandler(bootstrap *oracledb.Bootstrap) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		WriteJSON(response, http.StatusOK, HealthStatus{Status: "ok", Database: checkDatabase(request.Context(), bootstrap)})
+	}
+}
+
+// ReadinessHandler answers readiness probes: 503 while the database is still connecting or does not respond in time,
+// so no traffic is routed to the service until it can handle it
+func ReadinessHandler(bootstrap *oracledb.Bootstrap) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		health := HealthStatus{Status: "ready", Database: checkDatabase(request.Context(), bootstrap)}
+		status := http.StatusOK
+		if health.Database != nil && health.Database.Status != "up" {
+			health.Status = "not ready"
+			status = http.StatusServiceUnavailable
+		}
+		WriteJSON(response, status, health)
+	}
+}
@@ -0,0 +1,27 @@
+package httpserver
+
+import (
+	"net/http"
+	"time"
+
+	"go-on-oci-shared/logging"
+)
+
+// LoggingMiddleware logs the outcome of every request, with the request ID when RequestIDMiddleware runs before it
+func LoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: response}
+		start := time.Now()
+		next.ServeHTTP(recorder, request)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		logging.FromContext(request.Context()).Info("request completed",
+			"method", request.Method,
+			"path", request.URL.Path,
+			"status", recorder.status,
+			"bytes", recorder.bytes,
+			"durationMs", time.Since(start).Milliseconds(),
+			"remoteAddr", request.RemoteAddr)
+	})
+}
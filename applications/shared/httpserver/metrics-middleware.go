@@ -0,0 +1,44 @@
+package httpserver
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-on-oci-shared/metrics"
+)
+
+var (
+	httpRequestsTotal = metrics.NewCounterVec("http_requests_total",
+		"Number of HTTP requests handled, per route, method and status code.", "route", "method", "status")
+	httpRequestDuration = metrics.NewHistogramVec("http_request_duration_seconds",
+		"Time taken to handle HTTP requests, per route and method.", metrics.DEFAULT_LATENCY_BUCKETS, "route", "method")
+)
+
+// MetricsMiddleware counts and times requests. Requests are labeled with the pattern of the route in routes that handles
+// them (such as /people/ rather than /people/Mary), so the number of time series stays bounded.
+func MetricsMiddleware(routes *Router) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			route := routes.Route(request)
+			method := metricMethod(request.Method)
+			recorder := &statusRecorder{ResponseWriter: response}
+			start := time.Now()
+			next.ServeHTTP(recorder, request)
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+			httpRequestDuration.ObserveSince(start, route, method)
+			httpRequestsTotal.Inc(route, method, strconv.Itoa(recorder.status))
+		})
+	}
+}
+
+// metricMethod maps methods outside the standard set to OTHER, so that made-up methods do not create new time series
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
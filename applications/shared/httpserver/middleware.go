@@ -0,0 +1,75 @@
+// Package httpserver holds what the HTTP servers of the applications share: the router, the middleware chain in front of it
+// and running the server until it is asked to stop.
+package httpserver
+
+import (
+	"net/http"
+
+	"go-on-oci-shared/settings"
+)
+
+const (
+	ENV_KEY_CORS_ALLOWED_ORIGINS = "CORS_ALLOWED_ORIGINS"
+	ENV_KEY_API_TOKENS           = "API_TOKENS"
+	ENV_KEY_RATE_LIMIT           = "RATE_LIMIT"
+	ENV_KEY_RATE_LIMIT_BURST     = "RATE_LIMIT_BURST"
+	ENV_KEY_TRUSTED_PROXIES      = "TRUSTED_PROXIES"
+)
+
+// Middleware wraps a handler with behavior around it, such as logging or authentication
+type Middleware func(next http.Handler) http.Handler
+
+// Chain wraps the handler in the middlewares; the first middleware is the outermost, so it sees the request first
+// and the response last
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// MiddlewareConfig configures the middlewares that are optional: each is switched off while its setting is empty or 0
+type MiddlewareConfig struct {
+	// CORSAllowedOrigins is a comma separated list of origins that browsers may call the server from, or * for any origin
+	CORSAllowedOrigins string `json:"corsAllowedOrigins"`
+	// APITokens is a comma separated list of bearer tokens that callers must present
+	APITokens string `json:"apiTokens"`
+	// RateLimit is the number of requests per second a single client may make on average
+	RateLimit int `json:"rateLimit"`
+	// RateLimitBurst is the number of requests a client may make at once; defaults to RateLimit
+	RateLimitBurst int `json:"rateLimitBurst"`
+	// TrustedProxies is the number of proxies, such as a load balancer, in front of the server that append the address they
+	// received the request from to X-Forwarded-For; the rate limit applies to the address the outermost one received it
+	// from. With 0, the client is the peer of the connection, so all requests through a proxy share a single limit.
+	TrustedProxies int `json:"trustedProxies"`
+	// PublicPaths are the paths that do not require a token, such as those of probes and Prometheus
+	PublicPaths []string `json:"-"`
+}
+
+// MiddlewareSettings returns the environment variables and flags that override the MiddlewareConfig that middlewareConfig
+// returns from the configuration T of a server
+func MiddlewareSettings[T any](middlewareConfig func(config *T) *MiddlewareConfig) []settings.Setting[T] {
+	return []settings.Setting[T]{
+		{Flag: "cors-allowed-origins", EnvKey: ENV_KEY_CORS_ALLOWED_ORIGINS, Usage: "comma separated origins that browsers may call the server from, or * for any; empty for no CORS", Field: func(config *T) interface{} { return &middlewareConfig(config).CORSAllowedOrigins }},
+		{Flag: "api-tokens", EnvKey: ENV_KEY_API_TOKENS, Usage: "comma separated bearer tokens of which callers must present one; empty for no authentication", Secret: true, Field: func(config *T) interface{} { return &middlewareConfig(config).APITokens }},
+		{Flag: "rate-limit", EnvKey: ENV_KEY_RATE_LIMIT, Usage: "requests per second a client may make on average; 0 for no limit", Field: func(config *T) interface{} { return &middlewareConfig(config).RateLimit }},
+		{Flag: "rate-limit-burst", EnvKey: ENV_KEY_RATE_LIMIT_BURST, Usage: "requests a client may make at once; defaults to the rate limit", Field: func(config *T) interface{} { return &middlewareConfig(config).RateLimitBurst }},
+		{Flag: "trusted-proxies", EnvKey: ENV_KEY_TRUSTED_PROXIES, Usage: "proxies in front of the server that append to X-Forwarded-For, to rate limit the client behind them; 0 to use the peer address", Field: func(config *T) interface{} { return &middlewareConfig(config).TrustedProxies }},
+	}
+}
+
+// ServerHandler puts the middleware chain of the servers in front of the router: request IDs, access logging, tracing,
+// metrics, recovery from panics, CORS, rate limiting, authentication and compression, in that order
+func ServerHandler(router *Router, config MiddlewareConfig) http.Handler {
+	return Chain(router,
+		RequestIDMiddleware,
+		LoggingMiddleware,
+		TracingMiddleware(router),
+		MetricsMiddleware(router),
+		RecoveryMiddleware(router),
+		CORSMiddleware(config.CORSAllowedOrigins),
+		RateLimitMiddleware(config.RateLimit, config.RateLimitBurst, config.TrustedProxies),
+		AuthMiddleware(config.APITokens, config.PublicPaths...),
+		GzipMiddleware,
+	)
+}
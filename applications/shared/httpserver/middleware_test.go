@@ -0,0 +1,102 @@
+package httpserver
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	named := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(response, request)
+			})
+		}
+	}
+	handler := Chain(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		order = append(order, "handler")
+	}), named("first"), named("second"))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if strings.Join(order, ",") != "first,second,handler" {
+		t.Fatalf("want first,second,handler, got %v\n", order)
+	}
+}
+
+func TestGzipMiddleware(t *testing.T) {
+	handler := GzipMiddleware(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		response.Write([]byte("Hello " + request.URL.Query().Get("name") + "!"))
+	}))
+	request := httptest.NewRequest("GET", "/greet?name=Mary", nil)
+	request.Header.Set("Accept-Encoding", "gzip;q=0.5")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	reader, err := gzip.NewReader(response.Body)
+	if err != nil {
+		t.Fatalf("response is not gzipped: %s\n", err)
+	}
+	content, _ := ioutil.ReadAll(reader)
+	if string(content) != "Hello Mary!" || response.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("unexpected response %q with headers %v\n", content, response.Header())
+	}
+
+	request.Header.Set("Accept-Encoding", "gzip;q=0")
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Header().Get("Content-Encoding") != "" || response.Body.String() != "Hello Mary!" {
+		t.Fatalf("want an uncompressed response when gzip is refused, got %q\n", response.Body.String())
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(2, 3)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if allowed, _ := limiter.allow("10.0.0.1", now); !allowed {
+			t.Fatalf("want request %d of the burst to be allowed\n", i+1)
+		}
+	}
+	allowed, retryAfter := limiter.allow("10.0.0.1", now)
+	if allowed || retryAfter != 500*time.Millisecond {
+		t.Fatalf("want the fourth request to wait 500ms, got allowed %v and %s\n", allowed, retryAfter)
+	}
+	if allowed, _ := limiter.allow("10.0.0.2", now); !allowed {
+		t.Fatalf("want another client to have its own bucket\n")
+	}
+	if allowed, _ := limiter.allow("10.0.0.1", now.Add(500*time.Millisecond)); !allowed {
+		t.Fatalf("want a token after 500ms\n")
+	}
+}
+
+func TestClientAddress(t *testing.T) {
+	cases := []struct {
+		forwardedFor   []string
+		trustedProxies int
+		want           string
+	}{
+		{nil, 0, "10.0.0.9"},
+		{[]string{"203.0.113.7"}, 0, "10.0.0.9"},
+		{[]string{"203.0.113.7"}, 1, "203.0.113.7"},
+		// the client can put anything in front of what the proxies append
+		{[]string{"198.51.100.1, 203.0.113.7"}, 1, "203.0.113.7"},
+		{[]string{"198.51.100.1, 203.0.113.7", "10.0.0.5"}, 2, "203.0.113.7"},
+		{nil, 1, "10.0.0.9"},
+		{[]string{"203.0.113.7"}, 2, "10.0.0.9"},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest("GET", "/people", nil)
+		request.RemoteAddr = "10.0.0.9:43210"
+		for _, value := range c.forwardedFor {
+			request.Header.Add("X-Forwarded-For", value)
+		}
+		if client := clientAddress(request, c.trustedProxies); client != c.want {
+			t.Fatalf("%v behind %d proxies: want client %s, got %s\n", c.forwardedFor, c.trustedProxies, c.want, client)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package httpserver
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-on-oci-shared/logging"
+)
+
+// RATE_LIMIT_IDLE_EXPIRY is how long the bucket of a client that makes no requests is kept
+const RATE_LIMIT_IDLE_EXPIRY = 10 * time.Minute
+
+// RateLimitMiddleware limits every client, identified by its IP address, to rate requests per second on average with bursts of
+// up to burst requests; requests over the limit get 429 with a Retry-After header. A rate of 0 does nothing. Behind
+// trustedProxies proxies, the address of the client is taken from X-Forwarded-For, see clientAddress.
+func RateLimitMiddleware(rate int, burst int, trustedProxies int) Middleware {
+	return func(next http.Handler) http.Handler {
+		if rate <= 0 {
+			return next
+		}
+		limiter := newRateLimiter(float64(rate), burst)
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			client := clientAddress(request, trustedProxies)
+			allowed, retryAfter := limiter.allow(client, time.Now())
+			if allowed {
+				next.ServeHTTP(response, request)
+				return
+			}
+			logging.FromContext(request.Context()).Warn("request over the rate limit", "client", client, "path", request.URL.Path)
+			response.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(response, "429 too many requests", http.StatusTooManyRequests)
+		})
+	}
+}
+
+// clientAddress is the IP address of the client without the port. Each of the trustedProxies proxies appends the address it
+// received the request from to X-Forwarded-For, so the client is that many entries from the end; the entries before it are
+// whatever the client sent and can not be trusted. Without trusted proxies, or when the header has fewer entries than
+// expected, the client is the peer of the connection.
+func clientAddress(request *http.Request, trustedProxies int) string {
+	if trustedProxies > 0 {
+		forwardedFor := strings.Split(strings.Join(request.Header.Values("X-Forwarded-For"), ","), ",")
+		if len(forwardedFor) >= trustedProxies {
+			if client := strings.TrimSpace(forwardedFor[len(forwardedFor)-trustedProxies]); client != "" {
+				return client
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+// rateLimiter keeps a token bucket per client: a bucket holds up to burst tokens, refills at rate tokens per second
+// and every request takes a token
+type rateLimiter struct {
+	rate      float64
+	burst     float64
+	mutex     sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), buckets: map[string]*tokenBucket{}}
+}
+
+// allow takes a token from the bucket of the client; when there is none, it returns how long until the next token
+func (limiter *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	limiter.prune(now)
+	bucket, ok := limiter.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: limiter.burst, updated: now}
+		limiter.buckets[client] = bucket
+	}
+	bucket.tokens = math.Min(limiter.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*limiter.rate)
+	bucket.updated = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / limiter.rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// prune forgets the buckets of clients that have been idle for a while, so the number of buckets stays bounded
+func (limiter *rateLimiter) prune(now time.Time) {
+	if now.Sub(limiter.lastPrune) < RATE_LIMIT_IDLE_EXPIRY {
+		return
+	}
+	for client, bucket := range limiter.buckets {
+		if now.Sub(bucket.updated) > RATE_LIMIT_IDLE_EXPIRY {
+			delete(limiter.buckets, client)
+		}
+	}
+	limiter.lastPrune = now
+}
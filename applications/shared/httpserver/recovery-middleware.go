@@ -0,0 +1,64 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/metrics"
+)
+
+var httpHandlerPanics = metrics.NewCounterVec("http_handler_panics_total",
+	"Number of requests whose handler panicked, per route.", "route")
+
+// RecoveryMiddleware catches a panic in the handling of a request, so a single bad request does not take the server down.
+// The panic is logged with its stack and the request ID and counted per route in routes; the client gets a JSON 500 response,
+// or a broken connection when the response was already underway.
+func RecoveryMiddleware(routes *Router) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: response}
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+				route := routes.Route(request)
+				httpHandlerPanics.Inc(route)
+				logging.FromContext(request.Context()).Error("handler panicked",
+					"panic", fmt.Sprint(recovered),
+					"method", request.Method,
+					"route", route,
+					"path", request.URL.Path,
+					"stack", string(debug.Stack()))
+				if recorder.status != 0 {
+					// the status line has been sent already; aborting is the only way left to tell the client something went wrong
+					panic(http.ErrAbortHandler)
+				}
+				writePanicResponse(response, request)
+			}()
+			next.ServeHTTP(recorder, request)
+		})
+	}
+}
+
+func writePanicResponse(response http.ResponseWriter, request *http.Request) {
+	header := response.Header()
+	for _, name := range []string{"Content-Encoding", "Content-Length", "ETag", "Location"} {
+		header.Del(name)
+	}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Content-Type-Options", "nosniff")
+	response.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(response).Encode(ErrorResponse{
+		Status:    http.StatusInternalServerError,
+		Code:      "internal_error",
+		Message:   "The server ran into an unexpected problem; mention the request ID when reporting it",
+		RequestID: logging.RequestIDFromContext(request.Context()),
+	})
+}
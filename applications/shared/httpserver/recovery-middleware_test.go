@@ -0,0 +1,100 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/metrics"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	var output bytes.Buffer
+	defaultLogger := logging.Default()
+	logging.SetDefault(logging.NewLogger(&output, slog.LevelInfo, logging.JSON_LOG_FORMAT))
+	defer logging.SetDefault(defaultLogger)
+
+	router := NewRouter()
+	router.HandleFunc("/recovery-test/", func(response http.ResponseWriter, request *http.Request) {
+		var person *struct{ Name string }
+		response.Header().Set("ETag", `"1"`)
+		response.Write([]byte(person.Name))
+	}, http.MethodGet)
+	handler := RecoveryMiddleware(router)(router)
+	request := httptest.NewRequest("GET", "/recovery-test/Mary", nil)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request.WithContext(logging.ContextWithRequestID(context.Background(), "order-42")))
+
+	var body ErrorResponse
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not JSON: %s\n%s", err, response.Body.String())
+	}
+	if response.Code != http.StatusInternalServerError || body.Status != http.StatusInternalServerError || body.Code != "internal_error" || body.RequestID != "order-42" {
+		t.Fatalf("unexpected response %d %+v\n", response.Code, body)
+	}
+	if response.Header().Get("ETag") != "" {
+		t.Fatalf("want the headers of the failed handler dropped, got ETag %s\n", response.Header().Get("ETag"))
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal(output.Bytes(), &record); err != nil {
+		t.Fatalf("log record is not JSON: %s\n%s", err, output.String())
+	}
+	stack, _ := record["stack"].(string)
+	if record[logging.REQUEST_ID_KEY] != "order-42" || record["route"] != "/recovery-test/" || !strings.Contains(stack, "recovery-middleware_test.go") {
+		t.Fatalf("want the panic logged with request ID, route and stack, got %v\n", record)
+	}
+	var exposition bytes.Buffer
+	metrics.Default().WriteTo(&exposition)
+	if !strings.Contains(exposition.String(), `http_handler_panics_total{route="/recovery-test/"} 1`+"\n") {
+		t.Fatalf("want the panic counted, got\n%s", exposition.String())
+	}
+}
+
+func TestRecoveryMiddlewareAbortsStartedResponse(t *testing.T) {
+	defaultLogger := logging.Default()
+	logging.SetDefault(logging.NewLogger(&bytes.Buffer{}, slog.LevelInfo, logging.JSON_LOG_FORMAT))
+	defer logging.SetDefault(defaultLogger)
+
+	router := NewRouter()
+	handler := RecoveryMiddleware(router)(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		response.Write([]byte("half a response"))
+		panic("broken halfway")
+	}))
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Fatalf("want the handler aborted, got %v\n", recovered)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestRecoveryMiddlewareAnswersPanicAfterHeldBackStatus(t *testing.T) {
+	defaultLogger := logging.Default()
+	logging.SetDefault(logging.NewLogger(&bytes.Buffer{}, slog.LevelInfo, logging.JSON_LOG_FORMAT))
+	defer logging.SetDefault(defaultLogger)
+
+	router := NewRouter()
+	handler := Chain(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		response.Header().Set("Content-Type", "application/json")
+		response.WriteHeader(http.StatusCreated)
+		panic("broken before the body")
+	}), RecoveryMiddleware(router), GzipMiddleware)
+	request := httptest.NewRequest("POST", "/people", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	var body ErrorResponse
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not JSON: %s\n%q", err, response.Body.String())
+	}
+	if response.Code != http.StatusInternalServerError || body.Code != "internal_error" || response.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("want an uncompressed JSON 500, got %d %s %+v\n", response.Code, response.Header().Get("Content-Encoding"), body)
+	}
+}
@@ -0,0 +1,62 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"go-on-oci-shared/logging"
+)
+
+const (
+	REQUEST_ID_HEADER     = "X-Request-Id"
+	MAX_REQUEST_ID_LENGTH = 128
+)
+
+// statusRecorder remembers the status and size of a response, for logging and metrics once the handler is done
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	if recorder.status == 0 {
+		recorder.status = status
+	}
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *statusRecorder) Write(content []byte) (int, error) {
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	written, err := recorder.ResponseWriter.Write(content)
+	recorder.bytes += written
+	return written, err
+}
+
+// RequestIDMiddleware gives every request an ID: the X-Request-Id of the caller when it sent a usable one, a new one otherwise.
+// The ID is returned in the X-Request-Id response header and carried in the request context for logging.FromContext and
+// downstream calls.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		requestID := request.Header.Get(REQUEST_ID_HEADER)
+		if !validRequestID(requestID) {
+			requestID = logging.NewRequestID()
+		}
+		response.Header().Set(REQUEST_ID_HEADER, requestID)
+		next.ServeHTTP(response, request.WithContext(logging.ContextWithRequestID(request.Context(), requestID)))
+	})
+}
+
+// validRequestID accepts IDs of printable ASCII without spaces and of limited length, so callers can not inject into the logs
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > MAX_REQUEST_ID_LENGTH {
+		return false
+	}
+	for _, character := range requestID {
+		if character <= ' ' || character > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-on-oci-shared/logging"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var output bytes.Buffer
+	defaultLogger := logging.Default()
+	logging.SetDefault(logging.NewLogger(&output, slog.LevelInfo, logging.JSON_LOG_FORMAT))
+	defer logging.SetDefault(defaultLogger)
+
+	var handledID string
+	handler := Chain(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		handledID = logging.RequestIDFromContext(request.Context())
+		response.WriteHeader(http.StatusTeapot)
+	}), RequestIDMiddleware, LoggingMiddleware)
+	cases := []struct {
+		name, incoming string
+		propagated     bool
+	}{
+		{"caller sends an ID", "order-42", true},
+		{"caller sends no ID", "", false},
+		{"caller sends an ID with spaces", "order 42", false},
+		{"caller sends an overly long ID", strings.Repeat("x", MAX_REQUEST_ID_LENGTH+1), false},
+	}
+
+	for _, c := range cases {
+		output.Reset()
+		request := httptest.NewRequest("GET", "/greet", nil)
+		if c.incoming != "" {
+			request.Header.Set(REQUEST_ID_HEADER, c.incoming)
+		}
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+
+		returnedID := response.Header().Get(REQUEST_ID_HEADER)
+		if c.propagated && returnedID != c.incoming {
+			t.Fatalf("%s: want request ID %s, got %s\n", c.name, c.incoming, returnedID)
+		}
+		if !c.propagated && (returnedID == c.incoming || !validRequestID(returnedID)) {
+			t.Fatalf("%s: want a new request ID, got %q\n", c.name, returnedID)
+		}
+		if handledID != returnedID {
+			t.Fatalf("%s: handler saw request ID %s, response has %s\n", c.name, handledID, returnedID)
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal(output.Bytes(), &record); err != nil {
+			t.Fatalf("%s: log record is not JSON: %s\n%s", c.name, err, output.String())
+		}
+		if record[logging.REQUEST_ID_KEY] != returnedID || record["level"] != "INFO" || record["status"] != float64(http.StatusTeapot) {
+			t.Fatalf("%s: unexpected log record %v\n", c.name, record)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package httpserver
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Router dispatches requests on path and method. Paths are matched like http.ServeMux does: a pattern ending in a slash
+// matches the whole subtree, the longest pattern wins. A request for a known path with a method that is not registered
+// for it gets 405 with an Allow header; a request for an unknown path is handled by NotFound.
+type Router struct {
+	mux    *http.ServeMux
+	routes map[string]*route
+	// NotFound handles requests for paths without a route; http.NotFound when nil
+	NotFound http.Handler
+	// MethodNotAllowed handles requests with a method that the route does not support, after the Allow header is set;
+	// a plain text 405 when nil
+	MethodNotAllowed http.Handler
+}
+
+// route holds the handlers of a single path pattern per method
+type route struct {
+	router   *Router
+	handlers map[string]http.Handler
+}
+
+// NewRouter creates a router without routes
+func NewRouter() *Router {
+	return &Router{mux: http.NewServeMux(), routes: map[string]*route{}}
+}
+
+// Handle registers the handler for the path pattern and methods; GET implies HEAD
+func (router *Router) Handle(pattern string, handler http.Handler, methods ...string) {
+	patternRoute, ok := router.routes[pattern]
+	if !ok {
+		patternRoute = &route{router: router, handlers: map[string]http.Handler{}}
+		router.routes[pattern] = patternRoute
+		router.mux.Handle(pattern, patternRoute)
+	}
+	for _, method := range methods {
+		patternRoute.handlers[method] = handler
+	}
+}
+
+// HandleFunc registers the handler function for the path pattern and methods
+func (router *Router) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request), methods ...string) {
+	router.Handle(pattern, http.HandlerFunc(handler), methods...)
+}
+
+// Route returns the pattern that handles the request, or unmatched; it labels requests in metrics and traces
+func (router *Router) Route(request *http.Request) string {
+	if _, pattern := router.mux.Handler(request); pattern != "" {
+		return pattern
+	}
+	return "unmatched"
+}
+
+func (router *Router) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	handler, pattern := router.mux.Handler(request)
+	if pattern == "" {
+		notFound := router.NotFound
+		if notFound == nil {
+			notFound = http.NotFoundHandler()
+		}
+		notFound.ServeHTTP(response, request)
+		return
+	}
+	handler.ServeHTTP(response, request)
+}
+
+func (patternRoute *route) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	handler, ok := patternRoute.handlers[request.Method]
+	if !ok && request.Method == http.MethodHead {
+		handler, ok = patternRoute.handlers[http.MethodGet]
+	}
+	if ok {
+		handler.ServeHTTP(response, request)
+		return
+	}
+	response.Header().Set("Allow", patternRoute.allow())
+	if request.Method == http.MethodOptions {
+		response.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if patternRoute.router.MethodNotAllowed != nil {
+		patternRoute.router.MethodNotAllowed.ServeHTTP(response, request)
+		return
+	}
+	http.Error(response, "Method is not supported unfortunately. ", http.StatusMethodNotAllowed)
+}
+
+// allow lists the methods of the route for the Allow header, including HEAD when GET is supported and OPTIONS
+func (patternRoute *route) allow() string {
+	allowed := map[string]bool{http.MethodOptions: true}
+	for method := range patternRoute.handlers {
+		allowed[method] = true
+	}
+	if allowed[http.MethodGet] {
+		allowed[http.MethodHead] = true
+	}
+	var methods []string
+	for method := range allowed {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
@@ -0,0 +1,48 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	router := NewRouter()
+	handled := func(name string) http.HandlerFunc {
+		return func(response http.ResponseWriter, request *http.Request) {
+			response.Header().Set("X-Handled-By", name)
+		}
+	}
+	router.HandleFunc("/things", handled("things"), http.MethodGet, http.MethodPost)
+	router.HandleFunc("/things/", handled("thing"), http.MethodGet, http.MethodDelete)
+	router.NotFound = handled("not found")
+	cases := []struct {
+		method, path  string
+		status        int
+		handledBy     string
+		allow         string
+		expectedRoute string
+	}{
+		{"GET", "/things", http.StatusOK, "things", "", "/things"},
+		{"HEAD", "/things", http.StatusOK, "things", "", "/things"},
+		{"POST", "/things", http.StatusOK, "things", "", "/things"},
+		{"DELETE", "/things", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS, POST", "/things"},
+		{"OPTIONS", "/things/42", http.StatusNoContent, "", "DELETE, GET, HEAD, OPTIONS", "/things/"},
+		{"DELETE", "/things/42", http.StatusOK, "thing", "", "/things/"},
+		{"PUT", "/things/42", http.StatusMethodNotAllowed, "", "DELETE, GET, HEAD, OPTIONS", "/things/"},
+		{"GET", "/other", http.StatusOK, "not found", "", "unmatched"},
+	}
+
+	for _, c := range cases {
+		request := httptest.NewRequest(c.method, c.path, nil)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		if response.Code != c.status || response.Header().Get("X-Handled-By") != c.handledBy || response.Header().Get("Allow") != c.allow {
+			t.Fatalf("%s %s: want %d by %q with Allow %q, got %d by %q with Allow %q\n", c.method, c.path, c.status, c.handledBy, c.allow,
+				response.Code, response.Header().Get("X-Handled-By"), response.Header().Get("Allow"))
+		}
+		if route := router.Route(request); route != c.expectedRoute {
+			t.Fatalf("%s %s: want route %s, got %s\n", c.method, c.path, c.expectedRoute, route)
+		}
+	}
+}
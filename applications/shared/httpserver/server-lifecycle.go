@@ -0,0 +1,73 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go-on-oci-shared/logging"
+)
+
+const (
+	READ_HEADER_TIMEOUT = 5 * time.Second
+	READ_TIMEOUT        = 30 * time.Second
+	WRITE_TIMEOUT       = 60 * time.Second
+	IDLE_TIMEOUT        = 120 * time.Second
+
+	DEFAULT_SHUTDOWN_GRACE_PERIOD = 20 * time.Second
+)
+
+// NewServer returns an HTTP server for the port with timeouts, so slow or idle clients can not hold on to connections forever;
+// a nil handler serves http.DefaultServeMux
+func NewServer(port string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + port,
+		Handler:           handler,
+		ReadHeaderTimeout: READ_HEADER_TIMEOUT,
+		ReadTimeout:       READ_TIMEOUT,
+		WriteTimeout:      WRITE_TIMEOUT,
+		IdleTimeout:       IDLE_TIMEOUT,
+	}
+}
+
+// RunServer serves requests until SIGINT or SIGTERM arrives. It then stops accepting connections, gives in-flight requests
+// up to gracePeriod to complete and finally runs the cleanup functions (closing database pools and clients) in reverse order.
+func RunServer(server *http.Server, gracePeriod time.Duration, cleanups ...func() error) error {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	return runServer(server, gracePeriod, stop, cleanups...)
+}
+
+func runServer(server *http.Server, gracePeriod time.Duration, stop <-chan os.Signal, cleanups ...func() error) error {
+	serverErrors := make(chan error, 1)
+	go func() {
+		serverErrors <- server.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-serverErrors:
+	case received := <-stop:
+		logging.Info("shutting down and waiting for requests in flight", "signal", received, "gracePeriod", gracePeriod)
+		ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+		defer cancel()
+		err = server.Shutdown(ctx)
+		if err != nil {
+			logging.Warn("not all requests completed within the grace period", "error", err)
+			server.Close()
+		}
+	}
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		if cleanupErr := cleanups[i](); cleanupErr != nil {
+			logging.Error("problem during shutdown", "error", cleanupErr)
+		}
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
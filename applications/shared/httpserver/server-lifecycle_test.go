@@ -0,0 +1,66 @@
+package httpserver
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunServerCompletesRequestsInFlight(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		response.Write([]byte("done"))
+	})
+	server := NewServer("", handler)
+	server.Addr = net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+
+	var closed []string
+	stop := make(chan os.Signal, 1)
+	result := make(chan error, 1)
+	go func() {
+		result <- runServer(server, 5*time.Second, stop,
+			func() error { closed = append(closed, "database"); return nil },
+			func() error { closed = append(closed, "client"); return nil })
+	}()
+
+	body := make(chan string, 1)
+	go func() {
+		for {
+			response, err := http.Get("http://" + server.Addr + "/")
+			if err != nil {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			content, _ := ioutil.ReadAll(response.Body)
+			response.Body.Close()
+			body <- string(content)
+			return
+		}
+	}()
+
+	<-started
+	stop <- syscall.SIGTERM
+	if got := <-body; got != "done" {
+		t.Fatalf("request in flight: want done, got %q\n", got)
+	}
+	if err := <-result; err != nil {
+		t.Fatalf("runServer: %s\n", err)
+	}
+	if len(closed) != 2 || closed[0] != "client" || closed[1] != "database" {
+		t.Fatalf("cleanups should run in reverse order, got %v\n", closed)
+	}
+}
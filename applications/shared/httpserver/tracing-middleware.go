@@ -0,0 +1,40 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingMiddleware starts a server span for every request, continuing the trace of the caller when it sent a traceparent
+// header; the span is named after the route in routes that handles the request
+func TracingMiddleware(routes *Router) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			ctx := tracing.Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+			route := routes.Route(request)
+			ctx, span := tracing.Start(ctx, request.Method+" "+route, trace.SpanKindServer,
+				attribute.String("http.method", request.Method),
+				attribute.String("http.route", route),
+				attribute.String("http.target", request.URL.RequestURI()))
+			defer span.End()
+			if requestID := logging.RequestIDFromContext(ctx); requestID != "" {
+				span.SetAttributes(attribute.String("request.id", requestID))
+			}
+			recorder := &statusRecorder{ResponseWriter: response}
+			next.ServeHTTP(recorder, request.WithContext(ctx))
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+			span.SetAttributes(attribute.Int("http.status_code", recorder.status))
+			if recorder.status >= http.StatusInternalServerError {
+				tracing.RecordError(span, fmt.Errorf("%d %s", recorder.status, http.StatusText(recorder.status)))
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// Package logging sets up log/slog for the applications, writing JSON or key=value text, and carries the request ID of a
+// request through its context so that every record about the request names it.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	ENV_KEY_LOG_LEVEL  = "LOG_LEVEL"
+	ENV_KEY_LOG_FORMAT = "LOG_FORMAT"
+
+	JSON_LOG_FORMAT = "json"
+	TEXT_LOG_FORMAT = "text"
+
+	REQUEST_ID_KEY = "requestId"
+)
+
+// ParseLevel reads a level name such as debug, info, warn or error, in any case; empty is info
+func ParseLevel(text string) (slog.Level, error) {
+	var level slog.Level
+	if strings.TrimSpace(text) == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(text))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q; use debug, info, warn or error", text)
+	}
+	return level, nil
+}
+
+// NewLogger creates a logger that writes records of at least level to out in the format json or text, with the request ID
+// of the context the record is logged with
+func NewLogger(out io.Writer, level slog.Leveler, format string) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: replaceAttr}
+	var handler slog.Handler = slog.NewJSONHandler(out, options)
+	if format == TEXT_LOG_FORMAT {
+		handler = slog.NewTextHandler(out, options)
+	}
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// replaceAttr writes times in UTC and durations and other Stringers as text, instead of a number or a (mostly empty) JSON object
+func replaceAttr(groups []string, attr slog.Attr) slog.Attr {
+	switch attr.Value.Kind() {
+	case slog.KindTime:
+		if len(groups) == 0 && attr.Key == slog.TimeKey {
+			attr.Value = slog.TimeValue(attr.Value.Time().UTC())
+		}
+	case slog.KindDuration:
+		attr.Value = slog.StringValue(attr.Value.Duration().String())
+	case slog.KindAny:
+		if _, isError := attr.Value.Any().(error); !isError {
+			if stringer, ok := attr.Value.Any().(fmt.Stringer); ok {
+				attr.Value = slog.StringValue(stringer.String())
+			}
+		}
+	}
+	return attr
+}
+
+// contextHandler adds the request ID to the records, as bound by FromContext or else carried by the context they are logged with
+type contextHandler struct {
+	slog.Handler
+	requestID string
+}
+
+func (handler *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	requestID := handler.requestID
+	if requestID == "" {
+		requestID = RequestIDFromContext(ctx)
+	}
+	if requestID != "" {
+		record.AddAttrs(slog.String(REQUEST_ID_KEY, requestID))
+	}
+	return handler.Handler.Handle(ctx, record)
+}
+
+func (handler *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: handler.Handler.WithAttrs(attrs), requestID: handler.requestID}
+}
+
+func (handler *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: handler.Handler.WithGroup(name), requestID: handler.requestID}
+}
+
+// defaultLogger is used by the whole application; Configure replaces it with one set up from the environment
+var defaultLogger = NewLogger(os.Stderr, slog.LevelInfo, JSON_LOG_FORMAT)
+
+// Default returns the application logger
+func Default() *slog.Logger {
+	return defaultLogger
+}
+
+// SetDefault replaces the application logger, for instance to capture the records in a test
+func SetDefault(logger *slog.Logger) {
+	defaultLogger = logger
+}
+
+// Configure sets up the application logger from LOG_LEVEL and LOG_FORMAT and makes it the slog default, which routes the
+// standard log package through it, so that output of code that still calls log.Printf is structured as well
+func Configure() error {
+	level, err := ParseLevel(os.Getenv(ENV_KEY_LOG_LEVEL))
+	if err != nil {
+		return err
+	}
+	format := strings.ToLower(os.Getenv(ENV_KEY_LOG_FORMAT))
+	switch format {
+	case "":
+		format = JSON_LOG_FORMAT
+	case JSON_LOG_FORMAT, TEXT_LOG_FORMAT:
+	default:
+		return fmt.Errorf("unknown log format %q; use %s or %s", format, JSON_LOG_FORMAT, TEXT_LOG_FORMAT)
+	}
+	defaultLogger = NewLogger(os.Stderr, level, format)
+	slog.SetDefault(defaultLogger)
+	return nil
+}
+
+// With returns the application logger extended with the key-value pairs
+func With(args ...interface{}) *slog.Logger {
+	return defaultLogger.With(args...)
+}
+
+// Debug writes a debug record with the application logger; dropped unless LOG_LEVEL is debug
+func Debug(msg string, args ...interface{}) {
+	defaultLogger.Debug(msg, args...)
+}
+
+// Info writes an info record with the application logger
+func Info(msg string, args ...interface{}) {
+	defaultLogger.Info(msg, args...)
+}
+
+// Warn writes a warning record with the application logger
+func Warn(msg string, args ...interface{}) {
+	defaultLogger.Warn(msg, args...)
+}
+
+// Error writes an error record with the application logger
+func Error(msg string, args ...interface{}) {
+	defaultLogger.Error(msg, args...)
+}
+
+type contextKey int
+
+const requestIDContextKey contextKey = 0
+
+// NewRequestID returns a random identifier for correlating the log records of a request across services
+func NewRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+// ContextWithRequestID returns a context that carries the request ID
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by the context, or an empty string
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey).(string)
+	return requestID
+}
+
+// FromContext returns the application logger, bound to the request ID when the context carries one, so that records logged
+// without the context name the request as well
+func FromContext(ctx context.Context) *slog.Logger {
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		return defaultLogger
+	}
+	if handler, ok := defaultLogger.Handler().(*contextHandler); ok {
+		return slog.New(&contextHandler{Handler: handler.Handler, requestID: requestID})
+	}
+	return defaultLogger.With(REQUEST_ID_KEY, requestID)
+}
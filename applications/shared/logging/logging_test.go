@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggerLevels(t *testing.T) {
+	var output bytes.Buffer
+	warnLogger := NewLogger(&output, slog.LevelWarn, TEXT_LOG_FORMAT).With("component", "test")
+	warnLogger.Info("dropped")
+	warnLogger.Error("kept", "reason", "level is high enough")
+	expected := `level=ERROR msg=kept component=test reason="level is high enough"`
+	if lines := strings.Split(strings.TrimSpace(output.String()), "\n"); len(lines) != 1 || !strings.HasSuffix(lines[0], expected) {
+		t.Fatalf("want one record ending in %s, got %q\n", expected, output.String())
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Fatalf("want an error for an unknown level\n")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	var output bytes.Buffer
+	defaultLogger := Default()
+	SetDefault(NewLogger(&output, slog.LevelInfo, JSON_LOG_FORMAT))
+	defer SetDefault(defaultLogger)
+
+	FromContext(ContextWithRequestID(context.Background(), "order-42")).Info("handled", "error", errors.New("gone"), "took", 1500*time.Millisecond)
+	var record map[string]interface{}
+	if err := json.Unmarshal(output.Bytes(), &record); err != nil {
+		t.Fatalf("log record is not JSON: %s\n%s", err, output.String())
+	}
+	if record[REQUEST_ID_KEY] != "order-42" || record["error"] != "gone" || record["took"] != "1.5s" {
+		t.Fatalf("unexpected log record %v\n", record)
+	}
+	output.Reset()
+	Default().InfoContext(ContextWithRequestID(context.Background(), "order-43"), "handled")
+	if err := json.Unmarshal(output.Bytes(), &record); err != nil || record[REQUEST_ID_KEY] != "order-43" {
+		t.Fatalf("want the request ID of the context a record is logged with, got %s\n", output.String())
+	}
+	if RequestIDFromContext(context.Background()) != "" || len(NewRequestID()) != 32 {
+		t.Fatalf("want no request ID without one in the context and new ones of 32 hex digits\n")
+	}
+}
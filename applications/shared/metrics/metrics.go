@@ -0,0 +1,315 @@
+// Package metrics keeps the counters, gauges and histograms of an application and serves them to Prometheus in its text format.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	METRICS_PATH                 = "/metrics"
+	PROMETHEUS_TEXT_CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// DEFAULT_LATENCY_BUCKETS are the upper bounds in seconds of the latency histograms, from 5ms up to 10s
+var DEFAULT_LATENCY_BUCKETS = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metricFamily is a named metric with all of its label combinations, written in the Prometheus text exposition format
+type metricFamily interface {
+	writeTo(out *bytes.Buffer)
+}
+
+// Registry holds the metrics of the application and writes them for /metrics in the order they were registered
+type Registry struct {
+	mutex    sync.Mutex
+	families []metricFamily
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// defaultRegistry holds the metrics registered with the package-level functions
+var defaultRegistry = NewRegistry()
+
+// Default returns the registry that the package-level functions register with
+func Default() *Registry {
+	return defaultRegistry
+}
+
+func (registry *Registry) register(family metricFamily) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.families = append(registry.families, family)
+}
+
+// WriteTo writes all metrics in the Prometheus text format
+func (registry *Registry) WriteTo(out io.Writer) (int64, error) {
+	registry.mutex.Lock()
+	families := append([]metricFamily{}, registry.families...)
+	registry.mutex.Unlock()
+	var content bytes.Buffer
+	for _, family := range families {
+		family.writeTo(&content)
+	}
+	return content.WriteTo(out)
+}
+
+// Handler serves the metrics of the registry to Prometheus
+func Handler(registry *Registry) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodGet && request.Method != http.MethodHead {
+			response.Header().Set("Allow", "GET, HEAD")
+			http.Error(response, "Method is not supported unfortunately. ", http.StatusMethodNotAllowed)
+			return
+		}
+		response.Header().Set("Content-Type", PROMETHEUS_TEXT_CONTENT_TYPE)
+		registry.WriteTo(response)
+	}
+}
+
+// labeledValues keeps a value per combination of label values; the key is the label values joined with a separator
+type labeledValues struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	mutex      sync.Mutex
+	keys       []string
+	labels     map[string][]string
+}
+
+func (values *labeledValues) key(labelValues []string) string {
+	if len(labelValues) != len(values.labelNames) {
+		panic(fmt.Sprintf("metric %s has labels %v, got values %v", values.name, values.labelNames, labelValues))
+	}
+	key := strings.Join(labelValues, "\xff")
+	if _, ok := values.labels[key]; !ok {
+		values.labels[key] = append([]string{}, labelValues...)
+		values.keys = append(values.keys, key)
+		sort.Strings(values.keys)
+	}
+	return key
+}
+
+func (values *labeledValues) writeHeader(out *bytes.Buffer) {
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", values.name, escapeHelp(values.help), values.name, values.kind)
+}
+
+// labelPairs renders {name="value",...} for the label values, plus the extra pair (such as le for a bucket) when given
+func (values *labeledValues) labelPairs(labelValues []string, extraName string, extraValue string) string {
+	var pairs []string
+	for i, name := range values.labelNames {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, escapeLabelValue(labelValues[i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extraName, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// CounterVec is a counter, such as the number of requests, per combination of label values
+type CounterVec struct {
+	labeledValues
+	counts map[string]float64
+}
+
+// NewCounterVec registers a counter with the registry
+func (registry *Registry) NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	counter := &CounterVec{labeledValues: labeledValues{name: name, help: help, kind: "counter", labelNames: labelNames, labels: map[string][]string{}}, counts: map[string]float64{}}
+	registry.register(counter)
+	return counter
+}
+
+// Inc adds one to the counter for the label values
+func (counter *CounterVec) Inc(labelValues ...string) {
+	counter.Add(1, labelValues...)
+}
+
+// Add adds a non-negative amount to the counter for the label values
+func (counter *CounterVec) Add(amount float64, labelValues ...string) {
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+	counter.counts[counter.key(labelValues)] += amount
+}
+
+func (counter *CounterVec) writeTo(out *bytes.Buffer) {
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+	counter.writeHeader(out)
+	for _, key := range counter.keys {
+		fmt.Fprintf(out, "%s%s %s\n", counter.name, counter.labelPairs(counter.labels[key], "", ""), formatFloat(counter.counts[key]))
+	}
+}
+
+// GaugeVec is a value that goes up and down, such as the lag of a consumer, per combination of label values
+type GaugeVec struct {
+	labeledValues
+	values map[string]float64
+}
+
+// NewGaugeVec registers a gauge with the registry
+func (registry *Registry) NewGaugeVec(name string, help string, labelNames ...string) *GaugeVec {
+	gauge := &GaugeVec{labeledValues: labeledValues{name: name, help: help, kind: "gauge", labelNames: labelNames, labels: map[string][]string{}}, values: map[string]float64{}}
+	registry.register(gauge)
+	return gauge
+}
+
+// Set sets the gauge for the label values
+func (gauge *GaugeVec) Set(value float64, labelValues ...string) {
+	gauge.mutex.Lock()
+	defer gauge.mutex.Unlock()
+	gauge.values[gauge.key(labelValues)] = value
+}
+
+func (gauge *GaugeVec) writeTo(out *bytes.Buffer) {
+	gauge.mutex.Lock()
+	defer gauge.mutex.Unlock()
+	gauge.writeHeader(out)
+	for _, key := range gauge.keys {
+		fmt.Fprintf(out, "%s%s %s\n", gauge.name, gauge.labelPairs(gauge.labels[key], "", ""), formatFloat(gauge.values[key]))
+	}
+}
+
+// funcMetric is a metric without labels whose value is read at the time of the scrape, such as the statistics of a connection pool;
+// it is left out of the output while value reports false
+type funcMetric struct {
+	name  string
+	help  string
+	kind  string
+	value func() (float64, bool)
+}
+
+// NewGaugeFunc registers a gauge whose value is read from the function at every scrape
+func (registry *Registry) NewGaugeFunc(name string, help string, value func() (float64, bool)) {
+	registry.register(&funcMetric{name: name, help: help, kind: "gauge", value: value})
+}
+
+// NewCounterFunc registers a counter whose value is read from the function at every scrape
+func (registry *Registry) NewCounterFunc(name string, help string, value func() (float64, bool)) {
+	registry.register(&funcMetric{name: name, help: help, kind: "counter", value: value})
+}
+
+func (metric *funcMetric) writeTo(out *bytes.Buffer) {
+	value, ok := metric.value()
+	if !ok {
+		return
+	}
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", metric.name, escapeHelp(metric.help), metric.name, metric.kind, metric.name, formatFloat(value))
+}
+
+// HistogramVec counts observations, such as latencies, in cumulative buckets per combination of label values
+type HistogramVec struct {
+	labeledValues
+	buckets []float64
+	counts  map[string][]uint64
+	sums    map[string]float64
+	totals  map[string]uint64
+}
+
+// NewHistogramVec registers a histogram with the registry; buckets are the upper bounds in increasing order
+func (registry *Registry) NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	histogram := &HistogramVec{
+		labeledValues: labeledValues{name: name, help: help, kind: "histogram", labelNames: labelNames, labels: map[string][]string{}},
+		buckets:       buckets,
+		counts:        map[string][]uint64{},
+		sums:          map[string]float64{},
+		totals:        map[string]uint64{},
+	}
+	registry.register(histogram)
+	return histogram
+}
+
+// Observe records a value for the label values
+func (histogram *HistogramVec) Observe(value float64, labelValues ...string) {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+	key := histogram.key(labelValues)
+	counts, ok := histogram.counts[key]
+	if !ok {
+		counts = make([]uint64, len(histogram.buckets))
+		histogram.counts[key] = counts
+	}
+	for i, upperBound := range histogram.buckets {
+		if value <= upperBound {
+			counts[i]++
+		}
+	}
+	histogram.sums[key] += value
+	histogram.totals[key]++
+}
+
+// ObserveSince records the time passed since start in seconds
+func (histogram *HistogramVec) ObserveSince(start time.Time, labelValues ...string) {
+	histogram.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (histogram *HistogramVec) writeTo(out *bytes.Buffer) {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+	histogram.writeHeader(out)
+	for _, key := range histogram.keys {
+		labelValues := histogram.labels[key]
+		for i, upperBound := range histogram.buckets {
+			fmt.Fprintf(out, "%s_bucket%s %d\n", histogram.name, histogram.labelPairs(labelValues, "le", formatFloat(upperBound)), histogram.counts[key][i])
+		}
+		fmt.Fprintf(out, "%s_bucket%s %d\n", histogram.name, histogram.labelPairs(labelValues, "le", "+Inf"), histogram.totals[key])
+		fmt.Fprintf(out, "%s_sum%s %s\n", histogram.name, histogram.labelPairs(labelValues, "", ""), formatFloat(histogram.sums[key]))
+		fmt.Fprintf(out, "%s_count%s %d\n", histogram.name, histogram.labelPairs(labelValues, "", ""), histogram.totals[key])
+	}
+}
+
+// NewCounterVec registers a counter with the default registry
+func NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	return defaultRegistry.NewCounterVec(name, help, labelNames...)
+}
+
+// NewGaugeVec registers a gauge with the default registry
+func NewGaugeVec(name string, help string, labelNames ...string) *GaugeVec {
+	return defaultRegistry.NewGaugeVec(name, help, labelNames...)
+}
+
+// NewGaugeFunc registers a gauge whose value is read from the function at every scrape with the default registry
+func NewGaugeFunc(name string, help string, value func() (float64, bool)) {
+	defaultRegistry.NewGaugeFunc(name, help, value)
+}
+
+// NewCounterFunc registers a counter whose value is read from the function at every scrape with the default registry
+func NewCounterFunc(name string, help string, value func() (float64, bool)) {
+	defaultRegistry.NewCounterFunc(name, help, value)
+}
+
+// NewHistogramVec registers a histogram with the default registry; buckets are the upper bounds in increasing order
+func NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return defaultRegistry.NewHistogramVec(name, help, buckets, labelNames...)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(value)
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounterVec("test_requests_total", "Requests handled.", "route")
+	latency := registry.NewHistogramVec("test_latency_seconds", "Latency.", []float64{1, 2}, "route")
+	registry.NewGaugeFunc("test_pool_size", "Pool size.", func() (float64, bool) { return 3, true })
+	registry.NewGaugeFunc("test_missing", "Not there yet.", func() (float64, bool) { return 0, false })
+	requests.Inc("/people/")
+	requests.Add(2, `say "hi"`)
+	for _, value := range []float64{0.5, 1.5, 3} {
+		latency.Observe(value, "/greet")
+	}
+
+	var output bytes.Buffer
+	registry.WriteTo(&output)
+	expected := `# HELP test_requests_total Requests handled.
+# TYPE test_requests_total counter
+test_requests_total{route="/people/"} 1
+test_requests_total{route="say \"hi\""} 2
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{route="/greet",le="1"} 1
+test_latency_seconds_bucket{route="/greet",le="2"} 2
+test_latency_seconds_bucket{route="/greet",le="+Inf"} 3
+test_latency_seconds_sum{route="/greet"} 5
+test_latency_seconds_count{route="/greet"} 3
+# HELP test_pool_size Pool size.
+# TYPE test_pool_size gauge
+test_pool_size 3
+`
+	if output.String() != expected {
+		t.Fatalf("want\n%s\ngot\n%s", expected, output.String())
+	}
+}
+
+func TestHandler(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounterVec("test_scrapes_total", "Scrapes.").Inc()
+	response := httptest.NewRecorder()
+	Handler(registry)(response, httptest.NewRequest("GET", METRICS_PATH, nil))
+	if contentType := response.Header().Get("Content-Type"); contentType != PROMETHEUS_TEXT_CONTENT_TYPE || !bytes.HasSuffix(response.Body.Bytes(), []byte("\ntest_scrapes_total 1\n")) {
+		t.Fatalf("unexpected response %s %q\n", contentType, response.Body.String())
+	}
+	response = httptest.NewRecorder()
+	Handler(registry)(response, httptest.NewRequest("POST", METRICS_PATH, nil))
+	if response.Code != 405 || response.Header().Get("Allow") != "GET, HEAD" {
+		t.Fatalf("want 405 with Allow GET, HEAD for POST, got %d %s\n", response.Code, response.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,81 @@
+package oracledb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go-on-oci-shared/logging"
+)
+
+// ErrDatabaseUnavailable is returned while the connection to the database is still being established
+var ErrDatabaseUnavailable = errors.New("the database is not available yet")
+
+// Bootstrap connects to the database in the background, so the HTTP server can start before the database is reachable;
+// until the connection is there, the service runs degraded and reports that it is not ready
+type Bootstrap struct {
+	mutex  sync.RWMutex
+	db     *sql.DB
+	err    error
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+// StartBootstrap starts connecting with retries as configured; initialize (for example a schema migration)
+// runs on the new connection before Database hands it out. When initialize fails, the service stays unavailable with
+// that error, as it cannot work on a database that is not in the shape it expects.
+func StartBootstrap(ctx context.Context, config Config, initialize func(db *sql.DB) error) *Bootstrap {
+	return StartBootstrapWith(ctx, func(ctx context.Context) (*sql.DB, error) { return OpenWithRetry(ctx, config) }, initialize)
+}
+
+// StartBootstrapWith is StartBootstrap with open establishing the connection; open gets a context that Close cancels
+func StartBootstrapWith(ctx context.Context, open func(ctx context.Context) (*sql.DB, error), initialize func(db *sql.DB) error) *Bootstrap {
+	ctx, cancel := context.WithCancel(ctx)
+	bootstrap := &Bootstrap{err: ErrDatabaseUnavailable, done: make(chan struct{}), cancel: cancel}
+	go func() {
+		defer close(bootstrap.done)
+		db, err := open(ctx)
+		if err != nil {
+			logging.Error("could not connect to the database; the service stays unavailable", "error", err)
+		} else if initialize != nil {
+			if err = initialize(db); err != nil {
+				// db is kept, so Close still closes it
+				err = fmt.Errorf("initializing the database: %w", err)
+				logging.Error("problem in initializing the database; the service stays unavailable", "error", err)
+			}
+		}
+		bootstrap.mutex.Lock()
+		bootstrap.db, bootstrap.err = db, err
+		bootstrap.mutex.Unlock()
+		if err == nil {
+			logging.Info("connected to the database; the service is ready")
+		}
+	}()
+	return bootstrap
+}
+
+// Database returns the connection once it is established; before that ErrDatabaseUnavailable, or the reason the bootstrap gave up
+func (bootstrap *Bootstrap) Database() (*sql.DB, error) {
+	bootstrap.mutex.RLock()
+	defer bootstrap.mutex.RUnlock()
+	return bootstrap.db, bootstrap.err
+}
+
+// Wait blocks until the bootstrap has either connected or given up
+func (bootstrap *Bootstrap) Wait() (*sql.DB, error) {
+	<-bootstrap.done
+	return bootstrap.Database()
+}
+
+// Close stops connecting when the bootstrap is still at it and closes the connection pool once it is established
+func (bootstrap *Bootstrap) Close() error {
+	bootstrap.cancel()
+	db, _ := bootstrap.Wait()
+	if db == nil {
+		return nil
+	}
+	logging.Info("closing the database connection pool")
+	return db.Close()
+}
@@ -0,0 +1,386 @@
+// Package oracledb connects the applications to Oracle Database, with either the pure Go driver go-ora or godror,
+// from a configuration that names the database by host, port and service or by connect string.
+package oracledb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godror/godror"
+	go_ora "github.com/sijms/go-ora/v2"
+	"go-on-oci-shared/settings"
+)
+
+const (
+	GO_ORA_DRIVER = "go-ora"
+	GODROR_DRIVER = "godror"
+
+	DEFAULT_DB_PORT         = "1521"
+	DEFAULT_INITIAL_BACKOFF = 1 * time.Second
+	DEFAULT_MAX_BACKOFF     = 30 * time.Second
+	ENV_KEY_TNS_ADMIN       = "TNS_ADMIN"
+	TNSNAMES_FILE_NAME      = "tnsnames.ora"
+)
+
+// Config holds the connection details for an Oracle Database. The database is identified either by Server, Port and Service
+// or by ConnectString: a TNS alias (looked up in tnsnames.ora in ConfigDir), an Easy Connect (Plus) string or a full connect descriptor.
+// WalletLocation is only set for a TLS connection using a wallet.
+type Config struct {
+	Driver         string      `json:"driver"`
+	Service        string      `json:"service"`
+	Username       string      `json:"username"`
+	Server         string      `json:"server"`
+	Port           string      `json:"port"`
+	Password       string      `json:"password"`
+	WalletLocation string      `json:"walletLocation"`
+	ConnectString  string      `json:"connectString"`
+	ConfigDir      string      `json:"configDir"`
+	Pool           PoolConfig  `json:"pool"`
+	Retry          RetryConfig `json:"retry"`
+}
+
+// PoolConfig sizes the connection pool of the *sql.DB; zero values keep the defaults of database/sql
+type PoolConfig struct {
+	MaxOpenConns    int               `json:"maxOpenConns"`
+	MaxIdleConns    int               `json:"maxIdleConns"`
+	ConnMaxLifetime settings.Duration `json:"connMaxLifetime"`
+	ConnMaxIdleTime settings.Duration `json:"connMaxIdleTime"`
+}
+
+// RetryConfig controls how OpenWithRetry keeps trying to connect: the wait between attempts starts at InitialBackoff and doubles
+// up to MaxBackoff, with random jitter, until Deadline has passed; a Deadline of 0 means a single attempt
+type RetryConfig struct {
+	InitialBackoff settings.Duration `json:"initialBackoff"`
+	MaxBackoff     settings.Duration `json:"maxBackoff"`
+	Deadline       settings.Duration `json:"deadline"`
+}
+
+// Open opens a connection pool to the database with the driver selected in the configuration and verifies it with a ping
+func Open(dbConfig Config) (*sql.DB, error) {
+	if problems := dbConfig.Problems(); len(problems) > 0 {
+		return nil, fmt.Errorf("invalid database configuration: %s", strings.Join(problems, "; "))
+	}
+	var db *sql.DB
+	switch dbConfig.Driver {
+	case GO_ORA_DRIVER:
+		dataSourceName, err := goOraDataSourceName(dbConfig)
+		if err != nil {
+			return nil, err
+		}
+		db, err = sql.Open("oracle", dataSourceName)
+		if err != nil {
+			return nil, fmt.Errorf("error in sql.Open: %w", err)
+		}
+	case GODROR_DRIVER:
+		connectionParams, err := godrorConnectionParams(dbConfig)
+		if err != nil {
+			return nil, err
+		}
+		db = sql.OpenDB(godror.NewConnector(connectionParams))
+	}
+	dbConfig.Pool.apply(db)
+	err := db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error pinging db: %w", err)
+	}
+	return db, nil
+}
+
+func (pool PoolConfig) apply(db *sql.DB) {
+	if pool.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(time.Duration(pool.ConnMaxLifetime))
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(time.Duration(pool.ConnMaxIdleTime))
+	}
+}
+
+// OpenWithRetry opens the database like Open, but retries failed attempts with exponential backoff and jitter
+// until the retry deadline in the configuration has passed or the context is cancelled; an invalid configuration is not retried
+func OpenWithRetry(ctx context.Context, dbConfig Config) (*sql.DB, error) {
+	if problems := dbConfig.Problems(); len(problems) > 0 {
+		return nil, fmt.Errorf("invalid database configuration: %s", strings.Join(problems, "; "))
+	}
+	backoff, maxBackoff := time.Duration(dbConfig.Retry.InitialBackoff), time.Duration(dbConfig.Retry.MaxBackoff)
+	if backoff <= 0 {
+		backoff = DEFAULT_INITIAL_BACKOFF
+	}
+	if maxBackoff < backoff {
+		maxBackoff = DEFAULT_MAX_BACKOFF
+	}
+	deadline := time.Now().Add(time.Duration(dbConfig.Retry.Deadline))
+	jitter := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for attempt := 1; ; attempt++ {
+		db, err := Open(dbConfig)
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("Connected to the database at attempt %d", attempt)
+			}
+			return db, nil
+		}
+		// wait between half and one and a half times the backoff, so restarted instances do not retry in lockstep
+		wait := backoff/2 + time.Duration(jitter.Int63n(int64(backoff)))
+		if time.Now().Add(wait).After(deadline) {
+			return nil, fmt.Errorf("giving up connecting to the database after %d attempts: %w", attempt, err)
+		}
+		log.Printf("Attempt %d to connect to the database failed: %s; retrying in %s", attempt, err, wait.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// Problems lists everything that is missing or wrong in the configuration
+func (dbConfig Config) Problems() []string {
+	var problems []string
+	if dbConfig.Driver != GO_ORA_DRIVER && dbConfig.Driver != GODROR_DRIVER {
+		problems = append(problems, fmt.Sprintf("database.driver %q is unknown; use %s or %s", dbConfig.Driver, GO_ORA_DRIVER, GODROR_DRIVER))
+	}
+	required := [][2]string{{"database.username", dbConfig.Username}, {"database.password", dbConfig.Password}}
+	if dbConfig.ConnectString == "" {
+		required = append(required, [2]string{"database.service", dbConfig.Service}, [2]string{"database.server", dbConfig.Server})
+		if !ValidPort(dbConfig.Port) {
+			problems = append(problems, fmt.Sprintf("database.port %q is not a valid port", dbConfig.Port))
+		}
+	}
+	for _, setting := range required {
+		if setting[1] == "" {
+			problems = append(problems, setting[0]+" is required")
+		}
+	}
+	pool := dbConfig.Pool
+	if pool.MaxOpenConns < 0 || pool.MaxIdleConns < 0 || pool.ConnMaxLifetime < 0 || pool.ConnMaxIdleTime < 0 {
+		problems = append(problems, "database.pool settings can not be negative")
+	}
+	if dbConfig.Retry.InitialBackoff < 0 || dbConfig.Retry.MaxBackoff < 0 || dbConfig.Retry.Deadline < 0 {
+		problems = append(problems, "database.retry settings can not be negative")
+	}
+	if pool.MaxOpenConns > 0 && pool.MaxIdleConns > pool.MaxOpenConns {
+		problems = append(problems, fmt.Sprintf("database.pool.maxIdleConns %d exceeds maxOpenConns %d", pool.MaxIdleConns, pool.MaxOpenConns))
+	}
+	return problems
+}
+
+// ValidPort reports whether port is a TCP port number, for the database listener as well as for HTTP servers
+func ValidPort(port string) bool {
+	number, err := strconv.Atoi(port)
+	return err == nil && number > 0 && number <= 65535
+}
+
+// goOraDataSourceName builds the URL for the pure Go driver; go-ora only understands host, port and service or a full connect descriptor,
+// so a TNS alias is resolved from tnsnames.ora and an Easy Connect string is taken apart
+func goOraDataSourceName(dbConfig Config) (string, error) {
+	options := map[string]string{}
+	if dbConfig.WalletLocation != "" {
+		options["SSL"] = "enable"
+		options["SSL VERIFY"] = "false"
+		options["WALLET"] = dbConfig.WalletLocation
+	}
+	if dbConfig.ConnectString == "" {
+		port, _ := strconv.Atoi(dbConfig.Port)
+		return go_ora.BuildUrl(dbConfig.Server, port, dbConfig.Service, dbConfig.Username, dbConfig.Password, options), nil
+	}
+	descriptor := dbConfig.ConnectString
+	switch {
+	case strings.HasPrefix(descriptor, "("):
+	case isTNSAlias(descriptor):
+		var err error
+		descriptor, err = resolveTNSAlias(dbConfig.configDir(), descriptor)
+		if err != nil {
+			return "", err
+		}
+	default:
+		easyConnect, err := parseEasyConnect(descriptor)
+		if err != nil {
+			return "", err
+		}
+		if easyConnect.protocol == "tcps" {
+			options["SSL"] = "enable"
+			dnMatch := strings.ToLower(easyConnect.parameters.Get("ssl_server_dn_match"))
+			options["SSL VERIFY"] = strconv.FormatBool(dnMatch == "yes" || dnMatch == "on" || dnMatch == "true")
+		}
+		if walletLocation := easyConnect.parameters.Get("wallet_location"); walletLocation != "" {
+			options["WALLET"] = walletLocation
+		}
+		return go_ora.BuildUrl(easyConnect.host, easyConnect.port, easyConnect.service, dbConfig.Username, dbConfig.Password, options), nil
+	}
+	if strings.Contains(strings.ToLower(descriptor), "tcps") {
+		options["SSL"] = "enable"
+		if _, ok := options["SSL VERIFY"]; !ok {
+			options["SSL VERIFY"] = "false"
+		}
+	}
+	return go_ora.BuildJDBC(dbConfig.Username, dbConfig.Password, descriptor, options), nil
+}
+
+// godrorConnectionParams builds the parameters for the godror driver; the Oracle Client resolves TNS aliases and Easy Connect Plus strings itself.
+// The parameters are passed to a connector rather than as a DSN string, so no user name or password ever needs escaping.
+func godrorConnectionParams(dbConfig Config) (godror.ConnectionParams, error) {
+	connectionParams, err := godror.ParseDSN("")
+	if err != nil {
+		return connectionParams, err
+	}
+	connectionParams.Username = dbConfig.Username
+	connectionParams.Password = godror.NewPassword(dbConfig.Password)
+	connectionParams.ConfigDir = dbConfig.ConfigDir
+	connectionParams.ConnectString = dbConfig.ConnectString
+	if dbConfig.Pool.MaxOpenConns > 0 {
+		connectionParams.MaxSessions = dbConfig.Pool.MaxOpenConns
+	}
+	if dbConfig.ConnectString == "" {
+		connectionParams.ConnectString = net.JoinHostPort(dbConfig.Server, dbConfig.Port) + "/" + dbConfig.Service
+		if dbConfig.WalletLocation != "" {
+			connectionParams.ConnectString = "tcps://" + connectionParams.ConnectString + "?wallet_location=" + quoteEasyConnectValue(dbConfig.WalletLocation)
+		}
+	}
+	return connectionParams, nil
+}
+
+// configDir is the directory with tnsnames.ora: ConfigDir if set, else the directory in TNS_ADMIN, else the wallet directory
+func (dbConfig Config) configDir() string {
+	if dbConfig.ConfigDir != "" {
+		return dbConfig.ConfigDir
+	}
+	if tnsAdmin := os.Getenv(ENV_KEY_TNS_ADMIN); tnsAdmin != "" {
+		return tnsAdmin
+	}
+	return dbConfig.WalletLocation
+}
+
+// isTNSAlias reports whether a connect string is a net service name rather than an Easy Connect string or a connect descriptor
+func isTNSAlias(connectString string) bool {
+	return !strings.ContainsAny(connectString, "()/:?@ ")
+}
+
+// resolveTNSAlias returns the connect descriptor for an alias in the tnsnames.ora file in configDir
+func resolveTNSAlias(configDir string, alias string) (string, error) {
+	tnsnamesFile := filepath.Join(configDir, TNSNAMES_FILE_NAME)
+	content, err := ioutil.ReadFile(tnsnamesFile)
+	if err != nil {
+		return "", fmt.Errorf("resolving TNS alias %s: %w", alias, err)
+	}
+	var entries strings.Builder
+	for _, line := range strings.Split(string(content), "\n") {
+		if comment := strings.Index(line, "#"); comment >= 0 {
+			line = line[:comment]
+		}
+		entries.WriteString(line + "\n")
+	}
+	text := entries.String()
+	for position := 0; position < len(text); {
+		equals := strings.Index(text[position:], "=")
+		if equals < 0 {
+			break
+		}
+		names := text[position : position+equals]
+		start := position + equals + 1
+		depth, end := 0, -1
+		for i := start; i < len(text) && end < 0; i++ {
+			switch text[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					end = i + 1
+				}
+			}
+		}
+		if end < 0 {
+			break
+		}
+		for _, name := range strings.Split(names, ",") {
+			if strings.EqualFold(strings.TrimSpace(name), alias) {
+				return strings.Join(strings.Fields(text[start:end]), " "), nil
+			}
+		}
+		position = end
+	}
+	return "", fmt.Errorf("TNS alias %s not found in %s", alias, tnsnamesFile)
+}
+
+type easyConnect struct {
+	protocol   string
+	host       string
+	port       int
+	service    string
+	parameters url.Values
+}
+
+// parseEasyConnect takes apart an Easy Connect (Plus) string: [protocol://]host[:port][/service][:server][/instance][?parameter=value&...]
+func parseEasyConnect(connectString string) (easyConnect, error) {
+	result := easyConnect{protocol: "tcp", parameters: url.Values{}}
+	if separator := strings.Index(connectString, "://"); separator >= 0 {
+		result.protocol = strings.ToLower(connectString[:separator])
+		connectString = connectString[separator+3:]
+	}
+	if separator := strings.Index(connectString, "?"); separator >= 0 {
+		parameters, err := url.ParseQuery(connectString[separator+1:])
+		if err != nil {
+			return result, fmt.Errorf("invalid parameters in Easy Connect string: %w", err)
+		}
+		for name, values := range parameters {
+			for _, value := range values {
+				result.parameters.Add(strings.ToLower(name), strings.Trim(value, `"`))
+			}
+		}
+		connectString = connectString[:separator]
+	}
+	address := connectString
+	if separator := strings.Index(connectString, "/"); separator >= 0 {
+		address = connectString[:separator]
+		result.service = strings.SplitN(connectString[separator+1:], ":", 2)[0]
+		result.service = strings.SplitN(result.service, "/", 2)[0]
+	}
+	result.host = address
+	port := DEFAULT_DB_PORT
+	if strings.LastIndex(address, ":") > strings.LastIndex(address, "]") {
+		var err error
+		result.host, port, err = net.SplitHostPort(address)
+		if err != nil {
+			return result, fmt.Errorf("invalid address in Easy Connect string: %w", err)
+		}
+	}
+	result.host = strings.Trim(result.host, "[]")
+	result.port, _ = strconv.Atoi(port)
+	if result.host == "" || result.port == 0 {
+		return result, errors.New("the Easy Connect string needs a host and a valid port")
+	}
+	if result.protocol != "tcp" && result.protocol != "tcps" {
+		return result, fmt.Errorf("protocol %s in Easy Connect string is not supported", result.protocol)
+	}
+	return result, nil
+}
+
+// quoteEasyConnectValue double quotes a parameter value that contains characters with a special meaning in Easy Connect strings
+func quoteEasyConnectValue(value string) string {
+	if strings.ContainsAny(value, ` ?&="`) {
+		return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+	}
+	return value
+}
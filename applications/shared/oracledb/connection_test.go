@@ -0,0 +1,123 @@
+package oracledb
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-on-oci-shared/settings"
+)
+
+func TestGoOraDataSourceName(t *testing.T) {
+	configDir := t.TempDir()
+	tnsnames := "# demo databases\nlocal_xe = (DESCRIPTION=(ADDRESS=(PROTOCOL=tcp)(HOST=localhost)(PORT=1521))\n  (CONNECT_DATA=(SERVICE_NAME=XE)))\n" +
+		"adb_high, adb = (description= (address=(protocol=tcps)(port=1522)(host=adb.example.com))(connect_data=(service_name=adb_high.example.com)))\n"
+	if err := ioutil.WriteFile(filepath.Join(configDir, TNSNAMES_FILE_NAME), []byte(tnsnames), 0600); err != nil {
+		t.Fatal(err)
+	}
+	base := Config{Driver: GO_ORA_DRIVER, Username: "demo", Password: "p@ss/word?&", ConfigDir: configDir}
+	cases := []struct {
+		name     string
+		change   func(dbConfig *Config)
+		host     string
+		path     string
+		expected map[string]string
+	}{
+		{"server, port and service", func(dbConfig *Config) {
+			dbConfig.Server, dbConfig.Port, dbConfig.Service = "localhost", "1521", "XE"
+		}, "localhost:1521", "/XE", map[string]string{}},
+		{"wallet", func(dbConfig *Config) {
+			dbConfig.Server, dbConfig.Port, dbConfig.Service, dbConfig.WalletLocation = "adb.example.com", "1522", "adb_high", "/wallet dir"
+		}, "adb.example.com:1522", "/adb_high", map[string]string{"SSL": "enable", "WALLET": "/wallet dir"}},
+		{"Easy Connect Plus", func(dbConfig *Config) {
+			dbConfig.ConnectString = "tcps://adb.example.com:1522/adb_high?wallet_location=/wallet&ssl_server_dn_match=yes"
+		}, "adb.example.com:1522", "/adb_high", map[string]string{"SSL": "enable", "SSL VERIFY": "true", "WALLET": "/wallet"}},
+		{"Easy Connect without port", func(dbConfig *Config) {
+			dbConfig.ConnectString = "dbhost/orclpdb"
+		}, "dbhost:1521", "/orclpdb", map[string]string{}},
+		{"TNS alias", func(dbConfig *Config) {
+			dbConfig.ConnectString = "local_xe"
+		}, ":0", "/", map[string]string{"connStr": "(DESCRIPTION=(ADDRESS=(PROTOCOL=tcp)(HOST=localhost)(PORT=1521)) (CONNECT_DATA=(SERVICE_NAME=XE)))"}},
+		{"TNS alias over TLS", func(dbConfig *Config) {
+			dbConfig.ConnectString = "ADB"
+		}, ":0", "/", map[string]string{"SSL": "enable", "connStr": "(description= (address=(protocol=tcps)(port=1522)(host=adb.example.com))(connect_data=(service_name=adb_high.example.com)))"}},
+	}
+
+	for _, c := range cases {
+		dbConfig := base
+		c.change(&dbConfig)
+		dataSourceName, err := goOraDataSourceName(dbConfig)
+		if err != nil {
+			t.Fatalf("%s: %s\n", c.name, err)
+		}
+		parsed, err := url.Parse(dataSourceName)
+		if err != nil {
+			t.Fatalf("%s: %s is not a valid URL: %s\n", c.name, dataSourceName, err)
+		}
+		password, _ := parsed.User.Password()
+		if parsed.User.Username() != base.Username || password != base.Password {
+			t.Fatalf("%s: want credentials to survive escaping, got %s\n", c.name, parsed.User)
+		}
+		if parsed.Host != c.host || parsed.Path != c.path {
+			t.Fatalf("%s: want %s%s, got %s%s\n", c.name, c.host, c.path, parsed.Host, parsed.Path)
+		}
+		for option, value := range c.expected {
+			if result := parsed.Query().Get(option); result != value {
+				t.Fatalf("%s: want option %s=%s, got %s\n", c.name, option, value, result)
+			}
+		}
+	}
+}
+
+func TestGodrorConnectionParams(t *testing.T) {
+	dbConfig := Config{Driver: GODROR_DRIVER, Username: "demo", Password: `pa"ss word`, Server: "adb.example.com", Port: "1522", Service: "adb_high", WalletLocation: "/wallet dir"}
+	connectionParams, err := godrorConnectionParams(dbConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `tcps://adb.example.com:1522/adb_high?wallet_location="/wallet dir"`; connectionParams.ConnectString != expected {
+		t.Fatalf("want connect string %s, got %s\n", expected, connectionParams.ConnectString)
+	}
+	if connectionParams.Username != dbConfig.Username || connectionParams.Password.Secret() != dbConfig.Password {
+		t.Fatalf("want credentials %s/%s, got %s/%s\n", dbConfig.Username, dbConfig.Password, connectionParams.Username, connectionParams.Password.Secret())
+	}
+}
+
+func TestConfigProblems(t *testing.T) {
+	_, err := Open(Config{Driver: "oci8", ConnectString: "dbhost/orclpdb"})
+	if err == nil {
+		t.Fatal("invalid database configuration was accepted")
+	}
+	for _, problem := range []string{"database.driver", "database.username", "database.password"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Fatalf("want a problem with %s, got %s\n", problem, err)
+		}
+	}
+	if strings.Contains(err.Error(), "database.server") {
+		t.Fatalf("server is not required with a connect string, got %s\n", err)
+	}
+}
+
+func TestOpenWithRetry(t *testing.T) {
+	dbConfig := Config{Driver: GO_ORA_DRIVER, Username: "demo", Password: "demo", Server: "127.0.0.1", Port: "1", Service: "XE",
+		Retry: RetryConfig{InitialBackoff: settings.Duration(20 * time.Millisecond), MaxBackoff: settings.Duration(40 * time.Millisecond), Deadline: settings.Duration(300 * time.Millisecond)}}
+	started := time.Now()
+	_, err := OpenWithRetry(context.Background(), dbConfig)
+	if err == nil || !strings.Contains(err.Error(), "giving up") || strings.Contains(err.Error(), "after 1 attempts") {
+		t.Fatalf("want several failed attempts, got %v\n", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("want to give up at the deadline, took %s\n", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dbConfig.Retry.Deadline = settings.Duration(time.Hour)
+	if _, err := OpenWithRetry(ctx, dbConfig); err != context.Canceled {
+		t.Fatalf("want to stop when the context is cancelled, got %v\n", err)
+	}
+}
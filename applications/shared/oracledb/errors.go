@@ -0,0 +1,68 @@
+package oracledb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// ErrorClass is how an error returned from the database is reported to a client: the HTTP status, a code to act on and a
+// message that is safe to show, along with the ORA- code when the client can do something about it
+type ErrorClass struct {
+	Status  int
+	Code    string
+	Message string
+	OraCode int
+}
+
+var oraCodePattern = regexp.MustCompile(`ORA-(\d{5})`)
+
+// ErrorCode returns the ORA- error code carried by err (godror errors expose it through Code(), go-ora errors only in their message),
+// or 0 when err is not an Oracle error
+func ErrorCode(err error) int {
+	var codedError interface{ Code() int }
+	if errors.As(err, &codedError) {
+		return codedError.Code()
+	}
+	if match := oraCodePattern.FindStringSubmatch(err.Error()); match != nil {
+		code, _ := strconv.Atoi(match[1])
+		return code
+	}
+	return 0
+}
+
+// ClassifyError maps an error returned from the database to the response for the client:
+// unique constraint violations become 409, values that do not fit their column become 422 and lost connections become 503.
+// Any other error becomes a 500 with a generic message, as the driver text can reveal statements, tables and hosts.
+func ClassifyError(err error) ErrorClass {
+	oraCode := ErrorCode(err)
+	switch oraCode {
+	case 1:
+		return ErrorClass{Status: http.StatusConflict, Code: "unique_violation", Message: "A record with this key already exists", OraCode: oraCode}
+	case 1438:
+		return ErrorClass{Status: http.StatusUnprocessableEntity, Code: "value_too_large", Message: "A numeric value is larger than its column allows (age is at most 999)", OraCode: oraCode}
+	case 12899, 1401:
+		return ErrorClass{Status: http.StatusUnprocessableEntity, Code: "value_too_large", Message: "A text value is longer than its column allows (name at most 100, comment at most 1000 characters)", OraCode: oraCode}
+	case 1400:
+		return ErrorClass{Status: http.StatusUnprocessableEntity, Code: "missing_value", Message: "A required value is missing", OraCode: oraCode}
+	case 3113, 3114, 3135, 12170, 12514, 12541, 12543, 12545, 12564, 28547:
+		return ErrorClass{Status: http.StatusServiceUnavailable, Code: "database_unavailable", Message: "The connection to the database was lost", OraCode: oraCode}
+	}
+	if errors.Is(err, ErrDatabaseUnavailable) {
+		return ErrorClass{Status: http.StatusServiceUnavailable, Code: "database_unavailable", Message: "The database is not available yet; try again later"}
+	}
+	// before net.Error, which context.DeadlineExceeded implements as well
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return ErrorClass{Status: http.StatusServiceUnavailable, Code: "database_timeout", Message: "The database did not respond in time"}
+	}
+	var netError net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.As(err, &netError) {
+		return ErrorClass{Status: http.StatusServiceUnavailable, Code: "database_unavailable", Message: "The connection to the database was lost"}
+	}
+	return ErrorClass{Status: http.StatusInternalServerError, Code: "database_error", Message: "The database could not handle the request; mention the request ID when reporting it"}
+}
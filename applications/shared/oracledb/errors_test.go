@@ -0,0 +1,50 @@
+package oracledb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// godrorError mimics the errors of godror, which expose the ORA- code through Code()
+type godrorError struct{ code int }
+
+func (e godrorError) Error() string { return fmt.Sprintf("ORA-%05d: some message", e.code) }
+func (e godrorError) Code() int     { return e.code }
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err     error
+		status  int
+		code    string
+		oraCode int
+	}{
+		{errors.New("ORA-00001: unique constraint (DEMO.PEOPLE_PK) violated"), http.StatusConflict, "unique_violation", 1},
+		{fmt.Errorf("insert: %w", godrorError{1}), http.StatusConflict, "unique_violation", 1},
+		{errors.New("ORA-01438: value larger than specified precision allowed for this column"), http.StatusUnprocessableEntity, "value_too_large", 1438},
+		{errors.New(`ORA-12899: value too large for column "DEMO"."PEOPLE"."NAME"`), http.StatusUnprocessableEntity, "value_too_large", 12899},
+		{errors.New("ORA-01401: inserted value too large for column"), http.StatusUnprocessableEntity, "value_too_large", 1401},
+		{errors.New(`ORA-01400: cannot insert NULL into ("DEMO"."PEOPLE"."NAME")`), http.StatusUnprocessableEntity, "missing_value", 1400},
+		{errors.New("ORA-03113: end-of-file on communication channel"), http.StatusServiceUnavailable, "database_unavailable", 3113},
+		{godrorError{12541}, http.StatusServiceUnavailable, "database_unavailable", 12541},
+		{ErrDatabaseUnavailable, http.StatusServiceUnavailable, "database_unavailable", 0},
+		{driver.ErrBadConn, http.StatusServiceUnavailable, "database_unavailable", 0},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusServiceUnavailable, "database_timeout", 0},
+		{errors.New("ORA-00942: table or view does not exist"), http.StatusInternalServerError, "database_error", 0},
+		{errors.New("something else went wrong"), http.StatusInternalServerError, "database_error", 0},
+	}
+
+	for _, c := range cases {
+		class := ClassifyError(c.err)
+		if class.Status != c.status || class.Code != c.code || class.OraCode != c.oraCode {
+			t.Fatalf("%v: want %d %s ORA-%05d, got %+v\n", c.err, c.status, c.code, c.oraCode, class)
+		}
+		if strings.Contains(class.Message, "ORA-") {
+			t.Fatalf("%v: the message %q carries the driver text\n", c.err, class.Message)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package oracledb
+
+import (
+	"database/sql"
+	"time"
+
+	"go-on-oci-shared/metrics"
+)
+
+var (
+	dbStatementDuration = metrics.NewHistogramVec("db_statement_duration_seconds",
+		"Time taken by database statements, per statement.", metrics.DEFAULT_LATENCY_BUCKETS, "statement")
+	dbStatementErrors = metrics.NewCounterVec("db_statement_errors_total",
+		"Number of database statements that failed, per statement.", "statement")
+)
+
+// ObserveStatement records the duration of a statement that started at start and, when err is a failure, counts the error;
+// sql.ErrNoRows is an outcome rather than a failure
+func ObserveStatement(statement string, start time.Time, err error) {
+	dbStatementDuration.ObserveSince(start, statement)
+	if err != nil && err != sql.ErrNoRows {
+		dbStatementErrors.Inc(statement)
+	} else {
+		dbStatementErrors.Add(0, statement)
+	}
+}
+
+// RegisterPoolMetrics exposes the statistics of the connection pool that database returns; they are left out of /metrics
+// as long as there is no connection pool yet
+func RegisterPoolMetrics(database func() (*sql.DB, error)) {
+	stat := func(value func(stats sql.DBStats) float64) func() (float64, bool) {
+		return func() (float64, bool) {
+			db, err := database()
+			if err != nil || db == nil {
+				return 0, false
+			}
+			return value(db.Stats()), true
+		}
+	}
+	metrics.NewGaugeFunc("db_pool_max_open_connections", "Maximum number of open connections to the database.",
+		stat(func(stats sql.DBStats) float64 { return float64(stats.MaxOpenConnections) }))
+	metrics.NewGaugeFunc("db_pool_open_connections", "Number of established connections to the database, in use and idle.",
+		stat(func(stats sql.DBStats) float64 { return float64(stats.OpenConnections) }))
+	metrics.NewGaugeFunc("db_pool_in_use_connections", "Number of connections to the database currently in use.",
+		stat(func(stats sql.DBStats) float64 { return float64(stats.InUse) }))
+	metrics.NewGaugeFunc("db_pool_idle_connections", "Number of idle connections to the database.",
+		stat(func(stats sql.DBStats) float64 { return float64(stats.Idle) }))
+	metrics.NewCounterFunc("db_pool_wait_count_total", "Number of times a statement had to wait for a free connection.",
+		stat(func(stats sql.DBStats) float64 { return float64(stats.WaitCount) }))
+	metrics.NewCounterFunc("db_pool_wait_duration_seconds_total", "Total time statements waited for a free connection.",
+		stat(func(stats sql.DBStats) float64 { return stats.WaitDuration.Seconds() }))
+	metrics.NewCounterFunc("db_pool_max_idle_closed_total", "Number of connections closed because of the maximum number of idle connections.",
+		stat(func(stats sql.DBStats) float64 { return float64(stats.MaxIdleClosed) }))
+	metrics.NewCounterFunc("db_pool_max_lifetime_closed_total", "Number of connections closed because they reached their maximum lifetime.",
+		stat(func(stats sql.DBStats) float64 { return float64(stats.MaxLifetimeClosed) }))
+}
@@ -0,0 +1,47 @@
+package oracledb
+
+import (
+	"go-on-oci-shared/settings"
+)
+
+const (
+	ENV_KEY_DB_SERVICE            = "DB_SERVICE"
+	ENV_KEY_DB_USERNAME           = "DB_USERNAME"
+	ENV_KEY_DB_SERVER             = "DB_SERVER"
+	ENV_KEY_DB_PORT               = "DB_PORT"
+	ENV_KEY_DB_PASSWORD           = "DB_PASSWORD"
+	ENV_KEY_DB_WALLET_LOCATION    = "DB_WALLET_LOCATION"
+	ENV_KEY_DB_DRIVER             = "DB_DRIVER"
+	ENV_KEY_DB_CONNECT_STRING     = "DB_CONNECT_STRING"
+	ENV_KEY_DB_CONFIG_DIR         = "DB_CONFIG_DIR"
+	ENV_KEY_DB_MAX_OPEN_CONNS     = "DB_MAX_OPEN_CONNS"
+	ENV_KEY_DB_MAX_IDLE_CONNS     = "DB_MAX_IDLE_CONNS"
+	ENV_KEY_DB_CONN_MAX_LIFETIME  = "DB_CONN_MAX_LIFETIME"
+	ENV_KEY_DB_CONN_MAX_IDLE_TIME = "DB_CONN_MAX_IDLE_TIME"
+	ENV_KEY_DB_RETRY_DEADLINE     = "DB_RETRY_DEADLINE"
+	ENV_KEY_DB_RETRY_BACKOFF      = "DB_RETRY_BACKOFF"
+	ENV_KEY_DB_RETRY_MAX_BACKOFF  = "DB_RETRY_MAX_BACKOFF"
+)
+
+// Settings returns the environment variables and flags that override the database Config that dbConfig returns from the
+// configuration T of an application
+func Settings[T any](dbConfig func(config *T) *Config) []settings.Setting[T] {
+	return []settings.Setting[T]{
+		{Flag: "db-driver", EnvKey: ENV_KEY_DB_DRIVER, Usage: "database driver: go-ora (pure Go) or godror (requires Oracle Instant Client)", Field: func(config *T) interface{} { return &dbConfig(config).Driver }},
+		{Flag: "db-connect-string", EnvKey: ENV_KEY_DB_CONNECT_STRING, Usage: "TNS alias, Easy Connect (Plus) string or connect descriptor; replaces db-server, db-port and db-service", Field: func(config *T) interface{} { return &dbConfig(config).ConnectString }},
+		{Flag: "db-config-dir", EnvKey: ENV_KEY_DB_CONFIG_DIR, Usage: "directory with tnsnames.ora", Field: func(config *T) interface{} { return &dbConfig(config).ConfigDir }},
+		{Flag: "db-service", EnvKey: ENV_KEY_DB_SERVICE, Usage: "database service name", Field: func(config *T) interface{} { return &dbConfig(config).Service }},
+		{Flag: "db-username", EnvKey: ENV_KEY_DB_USERNAME, Usage: "database user", Field: func(config *T) interface{} { return &dbConfig(config).Username }},
+		{Flag: "db-server", EnvKey: ENV_KEY_DB_SERVER, Usage: "database host", Field: func(config *T) interface{} { return &dbConfig(config).Server }},
+		{Flag: "db-port", EnvKey: ENV_KEY_DB_PORT, Usage: "database listener port", Field: func(config *T) interface{} { return &dbConfig(config).Port }},
+		{Flag: "db-password", EnvKey: ENV_KEY_DB_PASSWORD, Usage: "database password", Secret: true, Field: func(config *T) interface{} { return &dbConfig(config).Password }},
+		{Flag: "db-wallet-location", EnvKey: ENV_KEY_DB_WALLET_LOCATION, Usage: "directory with the database wallet; leave empty for a connection without TLS", Field: func(config *T) interface{} { return &dbConfig(config).WalletLocation }},
+		{Flag: "db-max-open-conns", EnvKey: ENV_KEY_DB_MAX_OPEN_CONNS, Usage: "maximum number of open database connections; 0 for no limit", Field: func(config *T) interface{} { return &dbConfig(config).Pool.MaxOpenConns }},
+		{Flag: "db-max-idle-conns", EnvKey: ENV_KEY_DB_MAX_IDLE_CONNS, Usage: "maximum number of idle database connections", Field: func(config *T) interface{} { return &dbConfig(config).Pool.MaxIdleConns }},
+		{Flag: "db-conn-max-lifetime", EnvKey: ENV_KEY_DB_CONN_MAX_LIFETIME, Usage: "maximum time a database connection is reused, such as 30m", Field: func(config *T) interface{} { return &dbConfig(config).Pool.ConnMaxLifetime }},
+		{Flag: "db-conn-max-idle-time", EnvKey: ENV_KEY_DB_CONN_MAX_IDLE_TIME, Usage: "maximum time a database connection stays idle, such as 5m", Field: func(config *T) interface{} { return &dbConfig(config).Pool.ConnMaxIdleTime }},
+		{Flag: "db-retry-deadline", EnvKey: ENV_KEY_DB_RETRY_DEADLINE, Usage: "how long to keep trying to connect to the database, such as 5m; 0 for a single attempt", Field: func(config *T) interface{} { return &dbConfig(config).Retry.Deadline }},
+		{Flag: "db-retry-backoff", EnvKey: ENV_KEY_DB_RETRY_BACKOFF, Usage: "wait after the first failed attempt to connect to the database; doubles with every attempt", Field: func(config *T) interface{} { return &dbConfig(config).Retry.InitialBackoff }},
+		{Flag: "db-retry-max-backoff", EnvKey: ENV_KEY_DB_RETRY_MAX_BACKOFF, Usage: "longest wait between attempts to connect to the database", Field: func(config *T) interface{} { return &dbConfig(config).Retry.MaxBackoff }},
+	}
+}
@@ -0,0 +1,28 @@
+package oracledb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go-on-oci-shared/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceStatement starts a client span named spanName for a database statement on table and returns the function to call with
+// the outcome of the statement; that function ends the span and records the duration and any error of the statement in the metrics
+func TraceStatement(ctx context.Context, table string, spanName string, statement string) func(err error) {
+	start := time.Now()
+	_, span := tracing.Start(ctx, spanName, trace.SpanKindClient,
+		attribute.String("db.system", "oracle"),
+		attribute.String("db.operation", statement),
+		attribute.String("db.sql.table", table))
+	return func(err error) {
+		ObserveStatement(statement, start, err)
+		if err != sql.ErrNoRows {
+			tracing.RecordError(span, err)
+		}
+		span.End()
+	}
+}
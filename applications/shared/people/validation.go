@@ -0,0 +1,65 @@
+// Package people holds the rules that a person meets before any of the applications writes it to the PEOPLE table.
+package people
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TABLE_NAME is the table that holds the persons
+const TABLE_NAME = "PEOPLE"
+
+// MERGE_STATEMENT inserts a person or, when the name exists, updates its age and comment along with the last update time and
+// the row version that conditional writes check. The binds are the name, age and comment: single values for one person, or
+// arrays of them to merge a batch in one round trip.
+const MERGE_STATEMENT = `MERGE INTO ` + TABLE_NAME + ` t using (select :name name, :age age, :description description from dual) person
+	ON (t.name = person.name )
+	WHEN MATCHED THEN UPDATE SET age = person.age, description = person.description, updated_time = systimestamp, row_version = t.row_version + 1
+	WHEN NOT MATCHED THEN INSERT (t.name, t.age, t.description, t.updated_time) values (person.name, person.age, person.description, systimestamp) `
+
+// limits imposed by the columns of the PEOPLE table: NAME VARCHAR2(100), AGE NUMBER(3), DESCRIPTION VARCHAR2(1000)
+const (
+	MAX_NAME_LENGTH    = 100
+	MAX_AGE            = 999
+	MAX_COMMENT_LENGTH = 1000
+)
+
+// INVALID_PERSON_MESSAGE is the message of the response to a request with a person that Validate rejects
+const INVALID_PERSON_MESSAGE = "The person does not meet the constraints of the PEOPLE table"
+
+// ValidationErrors maps the JSON field name of each invalid field of a person to the reason it was rejected
+type ValidationErrors map[string]string
+
+func (validationErrors ValidationErrors) Error() string {
+	fields := make([]string, 0, len(validationErrors))
+	for field := range validationErrors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = fmt.Sprintf("%s: %s", field, validationErrors[field])
+	}
+	return "invalid person - " + strings.Join(messages, "; ")
+}
+
+// Validate checks the name, age and comment of a person against the limits of the PEOPLE table before it is written to the database;
+// it returns an empty map when the person is valid. Lengths are counted in bytes, as the columns use byte length semantics.
+func Validate(name string, age int, comment string) ValidationErrors {
+	validationErrors := ValidationErrors{}
+	if strings.TrimSpace(name) == "" {
+		validationErrors["name"] = "name is required"
+	} else if len(name) > MAX_NAME_LENGTH {
+		validationErrors["name"] = fmt.Sprintf("name cannot be longer than %d bytes", MAX_NAME_LENGTH)
+	}
+	if age < 0 {
+		validationErrors["age"] = "age cannot be negative"
+	} else if age > MAX_AGE {
+		validationErrors["age"] = fmt.Sprintf("age cannot be over %d", MAX_AGE)
+	}
+	if len(comment) > MAX_COMMENT_LENGTH {
+		validationErrors["comment"] = fmt.Sprintf("comment cannot be longer than %d bytes", MAX_COMMENT_LENGTH)
+	}
+	return validationErrors
+}
@@ -0,0 +1,39 @@
+package people
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		description string
+		name        string
+		age         int
+		comment     string
+		expected    ValidationErrors
+	}{
+		{"valid", "Mary", 42, "likes Go", ValidationErrors{}},
+		{"limits are inclusive", strings.Repeat("n", MAX_NAME_LENGTH), MAX_AGE, strings.Repeat("c", MAX_COMMENT_LENGTH), ValidationErrors{}},
+		{"age zero", "Baby", 0, "", ValidationErrors{}},
+		{"blank name", "  ", 1, "", ValidationErrors{"name": "name is required"}},
+		{"name counted in bytes", strings.Repeat("é", MAX_NAME_LENGTH/2+1), 1, "", ValidationErrors{"name": "name cannot be longer than 100 bytes"}},
+		{"negative age", "Mary", -1, "", ValidationErrors{"age": "age cannot be negative"}},
+		{"all invalid", "", MAX_AGE + 1, strings.Repeat("c", MAX_COMMENT_LENGTH+1), ValidationErrors{
+			"name":    "name is required",
+			"age":     "age cannot be over 999",
+			"comment": "comment cannot be longer than 1000 bytes",
+		}},
+	}
+	for _, c := range cases {
+		validationErrors := Validate(c.name, c.age, c.comment)
+		if !reflect.DeepEqual(validationErrors, c.expected) {
+			t.Fatalf("%s: want %v, got %v\n", c.description, c.expected, validationErrors)
+		}
+	}
+	expected := "invalid person - age: age cannot be negative; name: name is required"
+	if message := Validate("", -1, "").Error(); message != expected {
+		t.Fatalf("want error %q, got %q\n", expected, message)
+	}
+}
@@ -0,0 +1,363 @@
+// Package schema manages the schema that the applications share in Oracle Database: the PEOPLE table and the import tables
+// of the people file processor. Every application applies the same migrations, so they agree on what SCHEMA_VERSION records.
+package schema
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The schema is managed through the numbered scripts in the migrations directory:
+// NNNN_description.up.sql applies a change, NNNN_description.down.sql reverts it. Statements in a script are
+// terminated by a line holding a single slash, as in SQL*Plus. Applied versions are recorded in SCHEMA_VERSION
+// along with the checksum of their up script, so a script that is changed after it was applied is detected.
+// Note that Oracle commits DDL implicitly: a script that fails halfway is not rolled back and needs manual repair.
+// Applications that start at the same time take turns applying migrations: MigrateUp and MigrateDown hold an exclusive
+// DBMS_LOCK lock while they run, which needs EXECUTE on DBMS_LOCK for the schema owner.
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const (
+	SCHEMA_VERSION_TABLE_NAME = "SCHEMA_VERSION"
+	MIGRATIONS_DIRECTORY      = "migrations"
+	// MIGRATION_LOCK_ID identifies the DBMS_LOCK lock that serializes migrations; user locks take IDs up to 1073741823
+	MIGRATION_LOCK_ID              = 718120245
+	MIGRATION_LOCK_TIMEOUT_SECONDS = 300
+)
+
+// results of DBMS_LOCK.REQUEST and DBMS_LOCK.RELEASE
+const (
+	lockGranted    = 0
+	lockTimeout    = 1
+	lockAlreadyOwn = 4
+)
+
+const (
+	requestMigrationLockStatement = "BEGIN :result := DBMS_LOCK.REQUEST(id => :lockId, lockmode => DBMS_LOCK.X_MODE, timeout => :timeoutSeconds, release_on_commit => FALSE); END;"
+	releaseMigrationLockStatement = "BEGIN :result := DBMS_LOCK.RELEASE(id => :lockId); END;"
+)
+
+const createSchemaVersionTableStatement = "CREATE TABLE SCHEMA_VERSION ( VERSION NUMBER(10) PRIMARY KEY, DESCRIPTION VARCHAR2(200), CHECKSUM VARCHAR2(64), APPLIED_AT TIMESTAMP DEFAULT SYSTIMESTAMP)"
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version     int
+	Description string
+	Up          string
+	Down        string
+	Checksum    string
+}
+
+// statementRunner runs the statements of the migrations, on the connection pool or on the connection that holds the migration lock
+type statementRunner interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type appliedMigration struct {
+	Version   int
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// loadMigrations reads the embedded migration scripts, ordered by version
+func loadMigrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir(MIGRATIONS_DIRECTORY)
+	if err != nil {
+		return nil, err
+	}
+	migrationsByVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s does not follow the NNNN_description.up|down.sql naming convention", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		script, err := migrationFiles.ReadFile(path.Join(MIGRATIONS_DIRECTORY, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := migrationsByVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Description: strings.ReplaceAll(match[2], "_", " ")}
+			migrationsByVersion[version] = migration
+		}
+		if match[3] == "up" {
+			migration.Up = string(script)
+			checksum := sha256.Sum256(script)
+			migration.Checksum = hex.EncodeToString(checksum[:])
+		} else {
+			migration.Down = string(script)
+		}
+	}
+	migrations := make([]Migration, 0, len(migrationsByVersion))
+	for _, migration := range migrationsByVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d %s needs both an up and a down script", migration.Version, migration.Description)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// splitStatements breaks a script into the statements separated by lines holding a single slash;
+// comment lines are dropped and the terminating semicolon is removed from plain SQL statements (but kept for PL/SQL blocks)
+func splitStatements(script string) []string {
+	var statements []string
+	var statement []string
+	flush := func() {
+		text := strings.TrimSpace(strings.Join(statement, "\n"))
+		statement = nil
+		if text == "" {
+			return
+		}
+		upperText := strings.ToUpper(text)
+		if !strings.HasPrefix(upperText, "BEGIN") && !strings.HasPrefix(upperText, "DECLARE") {
+			text = strings.TrimSuffix(text, ";")
+		}
+		statements = append(statements, text)
+	}
+	for _, line := range strings.Split(script, "\n") {
+		trimmedLine := strings.TrimSpace(line)
+		switch {
+		case trimmedLine == "/":
+			flush()
+		case strings.HasPrefix(trimmedLine, "--"):
+		default:
+			statement = append(statement, strings.TrimRight(line, "\r"))
+		}
+	}
+	flush()
+	return statements
+}
+
+// ensureSchemaVersionTable creates SCHEMA_VERSION when it does not exist yet; an application that finds the table was created
+// by another one in the meantime (ORA-00955) carries on
+func ensureSchemaVersionTable(ctx context.Context, db statementRunner) error {
+	var tableCount int32
+	err := db.QueryRowContext(ctx, "SELECT count(table_name) FROM user_tables where table_name = :tablename", SCHEMA_VERSION_TABLE_NAME).Scan(&tableCount)
+	if err != nil {
+		return err
+	}
+	if tableCount == 0 {
+		_, err = db.ExecContext(ctx, createSchemaVersionTableStatement)
+		if err != nil && strings.Contains(err.Error(), "ORA-00955") {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create table %s: %w", SCHEMA_VERSION_TABLE_NAME, err)
+		}
+		log.Printf("Created table %s in Oracle Database", SCHEMA_VERSION_TABLE_NAME)
+	}
+	return nil
+}
+
+func appliedMigrations(ctx context.Context, db statementRunner) (map[int]appliedMigration, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version, checksum, applied_at FROM SCHEMA_VERSION ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]appliedMigration{}
+	for rows.Next() {
+		var migration appliedMigration
+		err = rows.Scan(&migration.Version, &migration.Checksum, &migration.AppliedAt)
+		if err != nil {
+			return nil, err
+		}
+		applied[migration.Version] = migration
+	}
+	return applied, rows.Err()
+}
+
+// verifyChecksums fails when the up script of an applied migration differs from the script it was applied with
+func verifyChecksums(migrations []Migration, applied map[int]appliedMigration) error {
+	for _, migration := range migrations {
+		if appliedVersion, ok := applied[migration.Version]; ok && appliedVersion.Checksum != migration.Checksum {
+			return fmt.Errorf("checksum mismatch for migration %04d %s: the script was changed after it was applied", migration.Version, migration.Description)
+		}
+	}
+	return nil
+}
+
+func executeScript(ctx context.Context, db statementRunner, script string) error {
+	for _, statement := range splitStatements(script) {
+		_, err := db.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadMigrationState returns all known migrations and the ones applied to the database, after verifying their checksums
+func loadMigrationState(ctx context.Context, db statementRunner) ([]Migration, map[int]appliedMigration, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, nil, err
+	}
+	err = ensureSchemaVersionTable(ctx, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrations, applied, verifyChecksums(migrations, applied)
+}
+
+// PendingMigrations returns the migrations that have not yet been applied to the database, in the order in which they will be applied
+func PendingMigrations(db *sql.DB) ([]Migration, error) {
+	return pendingMigrations(context.Background(), db)
+}
+
+func pendingMigrations(ctx context.Context, db statementRunner) ([]Migration, error) {
+	migrations, applied, err := loadMigrationState(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	pending := []Migration{}
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// withMigrationLock runs migrate on a connection that holds the migration lock, waiting up to MIGRATION_LOCK_TIMEOUT_SECONDS
+// for another application to release it. The lock belongs to the session rather than to a transaction, so the implicit
+// commits of DDL statements do not release it.
+func withMigrationLock(db *sql.DB, migrate func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var result int
+	_, err = conn.ExecContext(ctx, requestMigrationLockStatement, sql.Out{Dest: &result}, MIGRATION_LOCK_ID, MIGRATION_LOCK_TIMEOUT_SECONDS)
+	if err != nil {
+		return fmt.Errorf("failed to request the migration lock: %w", err)
+	}
+	switch result {
+	case lockGranted, lockAlreadyOwn:
+	case lockTimeout:
+		return fmt.Errorf("timed out after %d seconds waiting for another application to finish migrating the schema", MIGRATION_LOCK_TIMEOUT_SECONDS)
+	default:
+		return fmt.Errorf("failed to request the migration lock: DBMS_LOCK.REQUEST returned %d", result)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, releaseMigrationLockStatement, sql.Out{Dest: &result}, MIGRATION_LOCK_ID); err != nil || result != lockGranted {
+			log.Printf("Failed to release the migration lock (result %d): %v", result, err)
+		}
+	}()
+	return migrate(ctx, conn)
+}
+
+// MigrateUp applies all pending migrations in order of their version. When migrations are pending, it takes the migration lock
+// and reads the applied versions again, as another application may have applied them while this one waited for the lock.
+func MigrateUp(db *sql.DB) error {
+	pending, err := PendingMigrations(db)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+	return withMigrationLock(db, applyPendingMigrations)
+}
+
+func applyPendingMigrations(ctx context.Context, conn *sql.Conn) error {
+	pending, err := pendingMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	for _, migration := range pending {
+		log.Printf("Applying schema migration %04d %s", migration.Version, migration.Description)
+		err = executeScript(ctx, conn, migration.Up)
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %04d %s: %w", migration.Version, migration.Description, err)
+		}
+		_, err = conn.ExecContext(ctx, "INSERT INTO SCHEMA_VERSION ( VERSION, DESCRIPTION, CHECKSUM) VALUES (:version, :description, :checksum)",
+			migration.Version, migration.Description, migration.Checksum)
+		if err != nil {
+			return fmt.Errorf("failed to record migration %04d %s: %w", migration.Version, migration.Description, err)
+		}
+	}
+	return nil
+}
+
+// MigrateDown rolls back the most recently applied migrations, at most steps of them, while holding the migration lock
+func MigrateDown(db *sql.DB, steps int) error {
+	return withMigrationLock(db, func(ctx context.Context, conn *sql.Conn) error {
+		return rollBackMigrations(ctx, conn, steps)
+	})
+}
+
+func rollBackMigrations(ctx context.Context, conn *sql.Conn, steps int) error {
+	migrations, applied, err := loadMigrationState(ctx, conn)
+	if err != nil {
+		return err
+	}
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		log.Printf("Rolling back schema migration %04d %s", migration.Version, migration.Description)
+		err = executeScript(ctx, conn, migration.Down)
+		if err != nil {
+			return fmt.Errorf("failed to roll back migration %04d %s: %w", migration.Version, migration.Description, err)
+		}
+		_, err = conn.ExecContext(ctx, "DELETE FROM SCHEMA_VERSION WHERE version = :version", migration.Version)
+		if err != nil {
+			return fmt.Errorf("failed to remove migration %04d %s from %s: %w", migration.Version, migration.Description, SCHEMA_VERSION_TABLE_NAME, err)
+		}
+		steps--
+	}
+	return nil
+}
+
+// PrintMigrationStatus writes every known migration with the time it was applied, or pending when it was not
+func PrintMigrationStatus(db *sql.DB, out io.Writer) error {
+	migrations, applied, err := loadMigrationState(context.Background(), db)
+	if err != nil {
+		return err
+	}
+	for _, migration := range migrations {
+		state := "pending"
+		if appliedVersion, ok := applied[migration.Version]; ok {
+			state = "applied at " + appliedVersion.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(out, "%04d %-40s %s\n", migration.Version, migration.Description, state)
+	}
+	return nil
+}
+
+// RunMigrationCommand executes the command passed in the -migrate flag: up, down or status
+func RunMigrationCommand(db *sql.DB, command string, out io.Writer) error {
+	switch command {
+	case "up":
+		return MigrateUp(db)
+	case "down":
+		return MigrateDown(db, 1)
+	case "status":
+		return PrintMigrationStatus(db, out)
+	}
+	return fmt.Errorf("unknown migration command %s; use up, down or status", command)
+}
@@ -0,0 +1,10 @@
+-- creates the PEOPLE table, unless it was already created before schema migrations were introduced
+DECLARE
+  table_count NUMBER;
+BEGIN
+  SELECT count(table_name) INTO table_count FROM user_tables WHERE table_name = 'PEOPLE';
+  IF table_count = 0 THEN
+    EXECUTE IMMEDIATE 'CREATE TABLE PEOPLE ( NAME VARCHAR2(100), AGE NUMBER(3), DESCRIPTION VARCHAR2(1000), CREATION_TIME TIMESTAMP DEFAULT SYSTIMESTAMP)';
+  END IF;
+END;
+/
@@ -0,0 +1,10 @@
+-- adds the UPDATED_TIME column, unless InitializeDataServer already added it before schema migrations were introduced
+DECLARE
+  column_count NUMBER;
+BEGIN
+  SELECT count(column_name) INTO column_count FROM user_tab_columns WHERE table_name = 'PEOPLE' AND column_name = 'UPDATED_TIME';
+  IF column_count = 0 THEN
+    EXECUTE IMMEDIATE 'ALTER TABLE PEOPLE ADD ( UPDATED_TIME TIMESTAMP DEFAULT SYSTIMESTAMP)';
+  END IF;
+END;
+/
@@ -0,0 +1,2 @@
+ALTER TABLE PEOPLE DROP CONSTRAINT PEOPLE_PK
+/
@@ -0,0 +1,3 @@
+-- fails when PEOPLE contains duplicate or empty names; clean those up before applying this migration
+ALTER TABLE PEOPLE ADD CONSTRAINT PEOPLE_PK PRIMARY KEY (NAME)
+/
@@ -0,0 +1,2 @@
+ALTER TABLE PEOPLE DROP COLUMN ROW_VERSION
+/
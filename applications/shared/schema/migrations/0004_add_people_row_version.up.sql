@@ -0,0 +1,3 @@
+-- every write to a person increments its ROW_VERSION, from which the data-service derives the ETag of the person
+ALTER TABLE PEOPLE ADD ( ROW_VERSION NUMBER(10) DEFAULT 1 NOT NULL)
+/
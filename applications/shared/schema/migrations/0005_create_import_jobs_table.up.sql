@@ -0,0 +1,26 @@
+-- the people file imports that POST /people/imports enqueues; the workers claim QUEUED jobs and record their progress here
+CREATE TABLE IMPORT_JOBS (
+  ID VARCHAR2(32) PRIMARY KEY,
+  STATUS VARCHAR2(20) NOT NULL,
+  OBJECT_NAME VARCHAR2(1024) NOT NULL,
+  BUCKET_NAME VARCHAR2(256) NOT NULL,
+  FORMAT VARCHAR2(20),
+  REQUEST_ID VARCHAR2(128),
+  ATTEMPTS NUMBER(5) DEFAULT 0 NOT NULL,
+  SIZE_BYTES NUMBER(19),
+  BYTES_READ NUMBER(19) DEFAULT 0 NOT NULL,
+  PARSED NUMBER(10) DEFAULT 0 NOT NULL,
+  INSERTED NUMBER(10) DEFAULT 0 NOT NULL,
+  UPDATED NUMBER(10) DEFAULT 0 NOT NULL,
+  REJECTED NUMBER(10) DEFAULT 0 NOT NULL,
+  CHUNKS NUMBER(10) DEFAULT 0 NOT NULL,
+  REJECTIONS CLOB,
+  ERROR_MESSAGE VARCHAR2(4000),
+  CREATED_TIME TIMESTAMP DEFAULT SYSTIMESTAMP NOT NULL,
+  STARTED_TIME TIMESTAMP,
+  FINISHED_TIME TIMESTAMP,
+  UPDATED_TIME TIMESTAMP DEFAULT SYSTIMESTAMP NOT NULL
+)
+/
+CREATE INDEX IMPORT_JOBS_STATUS_IX ON IMPORT_JOBS (STATUS, CREATED_TIME)
+/
@@ -0,0 +1,21 @@
+-- records every version of a people file that was imported, so a retried import of the same version is skipped
+CREATE TABLE IMPORT_LEDGER (
+  BUCKET_NAME VARCHAR2(256) NOT NULL,
+  OBJECT_NAME VARCHAR2(1024) NOT NULL,
+  ETAG VARCHAR2(128) NOT NULL,
+  MD5 VARCHAR2(64),
+  VERSION_ID VARCHAR2(128),
+  SIZE_BYTES NUMBER(19),
+  FORMAT VARCHAR2(20),
+  PARSED NUMBER(10) DEFAULT 0 NOT NULL,
+  INSERTED NUMBER(10) DEFAULT 0 NOT NULL,
+  UPDATED NUMBER(10) DEFAULT 0 NOT NULL,
+  REJECTED NUMBER(10) DEFAULT 0 NOT NULL,
+  IMPORTS NUMBER(10) DEFAULT 1 NOT NULL,
+  JOB_ID VARCHAR2(32),
+  REQUEST_ID VARCHAR2(128),
+  FIRST_IMPORTED_TIME TIMESTAMP DEFAULT SYSTIMESTAMP NOT NULL,
+  IMPORTED_TIME TIMESTAMP DEFAULT SYSTIMESTAMP NOT NULL,
+  CONSTRAINT IMPORT_LEDGER_PK PRIMARY KEY (BUCKET_NAME, OBJECT_NAME, ETAG)
+)
+/
@@ -0,0 +1,2 @@
+ALTER TABLE IMPORT_JOBS DROP COLUMN FORCE
+/
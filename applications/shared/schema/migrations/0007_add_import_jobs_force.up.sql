@@ -0,0 +1,3 @@
+-- an import job with FORCE set imports its file even when the ledger shows that version was imported already
+ALTER TABLE IMPORT_JOBS ADD ( FORCE NUMBER(1) DEFAULT 0 NOT NULL)
+/
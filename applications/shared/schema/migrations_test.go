@@ -0,0 +1,91 @@
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrationsAreNumberedConsecutively(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loading migrations: %s\n", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatalf("want embedded migrations\n")
+	}
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Fatalf("want migration %04d at position %d, got %04d %s\n", i+1, i, migration.Version, migration.Description)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	cases := []struct {
+		name     string
+		script   string
+		expected []string
+	}{
+		{"empty", "", nil},
+		{"only comments", "-- nothing to do\n  -- really\n", nil},
+		{"statement without terminator", "ALTER TABLE PEOPLE ADD (VERSION NUMBER(10));", []string{"ALTER TABLE PEOPLE ADD (VERSION NUMBER(10))"}},
+		{"statements separated by slashes",
+			"-- people\nCREATE TABLE PEOPLE (NAME VARCHAR2(100));\n/\n\nCREATE INDEX PEOPLE_AGE ON PEOPLE (AGE);\r\n  /  \n",
+			[]string{"CREATE TABLE PEOPLE (NAME VARCHAR2(100))", "CREATE INDEX PEOPLE_AGE ON PEOPLE (AGE)"}},
+		{"PL/SQL blocks keep their semicolons",
+			"BEGIN\n  EXECUTE IMMEDIATE 'DROP TABLE PEOPLE';\nEND;\n/\ndeclare\n  n number;\nbegin\n  null;\nend;\n/",
+			[]string{"BEGIN\n  EXECUTE IMMEDIATE 'DROP TABLE PEOPLE';\nEND;", "declare\n  n number;\nbegin\n  null;\nend;"}},
+		{"empty statements between slashes", "/\n/\nSELECT 1 FROM DUAL\n/\n/", []string{"SELECT 1 FROM DUAL"}},
+		{"slash inside a line", "SELECT 4 / 2 FROM DUAL;", []string{"SELECT 4 / 2 FROM DUAL"}},
+	}
+	for _, c := range cases {
+		if statements := splitStatements(c.script); !reflect.DeepEqual(statements, c.expected) {
+			t.Fatalf("%s: want %q, got %q\n", c.name, c.expected, statements)
+		}
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Description: "create people table", Checksum: "aaa"},
+		{Version: 2, Description: "add people updated time", Checksum: "bbb"},
+	}
+	cases := []struct {
+		name    string
+		applied map[int]appliedMigration
+		valid   bool
+	}{
+		{"nothing applied", map[int]appliedMigration{}, true},
+		{"all applied unchanged", map[int]appliedMigration{1: {Version: 1, Checksum: "aaa"}, 2: {Version: 2, Checksum: "bbb"}}, true},
+		{"unknown version applied by a newer release", map[int]appliedMigration{1: {Version: 1, Checksum: "aaa"}, 3: {Version: 3, Checksum: "ccc"}}, true},
+		{"script changed after it was applied", map[int]appliedMigration{1: {Version: 1, Checksum: "aaa"}, 2: {Version: 2, Checksum: "changed"}}, false},
+	}
+	for _, c := range cases {
+		err := verifyChecksums(migrations, c.applied)
+		if (err == nil) != c.valid {
+			t.Fatalf("%s: want valid %v, got error %v\n", c.name, c.valid, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "0002 add people updated time") {
+			t.Fatalf("%s: want the error to name the changed migration, got %s\n", c.name, err)
+		}
+	}
+}
+
+func TestEmbeddedChecksumsMatchScripts(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loading migrations: %s\n", err)
+	}
+	for _, migration := range migrations {
+		checksum := sha256.Sum256([]byte(migration.Up))
+		if migration.Checksum != hex.EncodeToString(checksum[:]) {
+			t.Fatalf("want the checksum of migration %04d to be the SHA-256 of its up script\n", migration.Version)
+		}
+		if len(splitStatements(migration.Up)) == 0 || len(splitStatements(migration.Down)) == 0 {
+			t.Fatalf("want statements in both scripts of migration %04d\n", migration.Version)
+		}
+	}
+}
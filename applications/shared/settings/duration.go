@@ -0,0 +1,26 @@
+// Package settings builds the configuration of an application in layers, from defaults, a config file, environment
+// variables and command line flags, out of the table of settings the application declares.
+package settings
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Duration is a time.Duration that is written as text, such as 30s or 5m, in JSON
+type Duration time.Duration
+
+func (duration Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(duration).String())
+}
+
+func (duration *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	err := json.Unmarshal(data, &text)
+	if err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(text)
+	*duration = Duration(parsed)
+	return err
+}
@@ -0,0 +1,145 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ENV_KEY_CONFIG_FILE = "CONFIG_FILE"
+
+	REDACTED = "*****"
+)
+
+// Setting ties a configuration value to the environment variable and the command line flag that can override it;
+// Field returns a pointer to the value in the configuration T: a *string, *int or *Duration
+type Setting[T any] struct {
+	Flag   string
+	EnvKey string
+	Usage  string
+	Secret bool
+	Field  func(config *T) interface{}
+}
+
+// Load registers the flags of the settings with the flag set, parses the arguments and builds the configuration in layers:
+// the defaults that config holds, then the JSON or YAML config file (set with -config or CONFIG_FILE), then environment
+// variables and finally the flags that were set explicitly. The configuration is not validated.
+func Load[T any](flags *flag.FlagSet, arguments []string, config *T, settings []Setting[T]) error {
+	configFile := flags.String("config", "", "JSON or YAML (.yaml, .yml) configuration file; overrides the defaults, is overridden by environment variables and flags")
+	flagValues := make([]*string, len(settings))
+	for i, setting := range settings {
+		flagValues[i] = flags.String(setting.Flag, "", fmt.Sprintf("%s (environment variable %s)", setting.Usage, setting.EnvKey))
+	}
+	err := flags.Parse(arguments)
+	if err != nil {
+		return err
+	}
+
+	if *configFile == "" {
+		*configFile = os.Getenv(ENV_KEY_CONFIG_FILE)
+	}
+	if *configFile != "" {
+		err := readConfigFile(*configFile, config)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, setting := range settings {
+		if value, ok := os.LookupEnv(setting.EnvKey); ok {
+			err := setValue(setting.Field(config), value)
+			if err != nil {
+				return fmt.Errorf("environment variable %s: %w", setting.EnvKey, err)
+			}
+		}
+	}
+	explicitFlags := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { explicitFlags[f.Name] = true })
+	for i, setting := range settings {
+		if explicitFlags[setting.Flag] {
+			err := setValue(setting.Field(config), *flagValues[i])
+			if err != nil {
+				return fmt.Errorf("flag -%s: %w", setting.Flag, err)
+			}
+		}
+	}
+	return nil
+}
+
+// readConfigFile decodes the config file into config: YAML when its name ends in .yaml or .yml, JSON otherwise. YAML is
+// converted to JSON first, so both formats use the same field names and duration texts, and reject unknown fields alike.
+func readConfigFile[T any](fileName string, config *T) error {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		var document interface{}
+		if err = yaml.Unmarshal(content, &document); err == nil {
+			content, err = json.Marshal(document)
+		}
+		if err != nil {
+			return fmt.Errorf("parsing config file %s: %w", fileName, err)
+		}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(config)
+	if err != nil {
+		return fmt.Errorf("parsing config file %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// setValue parses the text of an environment variable or flag into the configuration value it overrides
+func setValue(field interface{}, text string) error {
+	switch value := field.(type) {
+	case *string:
+		*value = text
+	case *int:
+		number, err := strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", text)
+		}
+		*value = number
+	case *Duration:
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", text)
+		}
+		*value = Duration(duration)
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration in which the values of all secret settings are masked
+func Redacted[T any](config T, settings []Setting[T]) T {
+	for _, setting := range settings {
+		if value, ok := setting.Field(&config).(*string); setting.Secret && ok && *value != "" {
+			*value = REDACTED
+		}
+	}
+	return config
+}
+
+// Print writes the configuration as JSON, with secrets redacted
+func Print[T any](config T, settings []Setting[T], out io.Writer) error {
+	content, err := json.MarshalIndent(Redacted(config, settings), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(content))
+	return err
+}
@@ -0,0 +1,104 @@
+package settings
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Port     string   `json:"port"`
+	Host     string   `json:"host"`
+	User     string   `json:"user"`
+	Password string   `json:"password"`
+	Workers  int      `json:"workers"`
+	Timeout  Duration `json:"timeout"`
+}
+
+var testSettings = []Setting[testConfig]{
+	{Flag: "port", EnvKey: "TEST_PORT", Usage: "port", Field: func(config *testConfig) interface{} { return &config.Port }},
+	{Flag: "host", EnvKey: "TEST_HOST", Usage: "host", Field: func(config *testConfig) interface{} { return &config.Host }},
+	{Flag: "user", EnvKey: "TEST_USER", Usage: "user", Field: func(config *testConfig) interface{} { return &config.User }},
+	{Flag: "password", EnvKey: "TEST_PASSWORD", Usage: "password", Secret: true, Field: func(config *testConfig) interface{} { return &config.Password }},
+	{Flag: "workers", EnvKey: "TEST_WORKERS", Usage: "workers", Field: func(config *testConfig) interface{} { return &config.Workers }},
+	{Flag: "timeout", EnvKey: "TEST_TIMEOUT", Usage: "timeout", Field: func(config *testConfig) interface{} { return &config.Timeout }},
+}
+
+func TestLoadLayers(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	content := `{"port":"9090","host":"file-host","user":"file-user","password":"secret","timeout":"20s"}`
+	if err := ioutil.WriteFile(configFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_HOST", "env-host")
+	t.Setenv("TEST_USER", "env-user")
+	t.Setenv("TEST_WORKERS", "3")
+
+	config := testConfig{Port: "8080", Workers: 1}
+	err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", configFile, "-user", "flag-user"}, &config, testSettings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := testConfig{Port: "9090", Host: "env-host", User: "flag-user", Password: "secret", Workers: 3, Timeout: Duration(20 * time.Second)}
+	if config != expected {
+		t.Fatalf("want %+v, got %+v\n", expected, config)
+	}
+
+	var out bytes.Buffer
+	Print(config, testSettings, &out)
+	if strings.Contains(out.String(), "secret") || !strings.Contains(out.String(), REDACTED) || !strings.Contains(out.String(), `"20s"`) {
+		t.Fatalf("want the password redacted and the timeout as text in %s\n", out.String())
+	}
+	if config.Password != "secret" {
+		t.Fatal("want Print to leave the configuration itself alone")
+	}
+}
+
+func TestLoadRejectsInvalidValues(t *testing.T) {
+	cases := []struct {
+		arguments []string
+		problem   string
+	}{
+		{[]string{"-workers", "many"}, "flag -workers"},
+		{[]string{"-timeout", "soon"}, "flag -timeout"},
+		{[]string{"-config", filepath.Join(os.TempDir(), "no-such-config.json")}, "reading config file"},
+	}
+	for _, c := range cases {
+		var config testConfig
+		err := Load(flag.NewFlagSet("test", flag.ContinueOnError), c.arguments, &config, testSettings)
+		if err == nil || !strings.Contains(err.Error(), c.problem) {
+			t.Fatalf("%v: want an error about %s, got %v\n", c.arguments, c.problem, err)
+		}
+	}
+}
+
+func TestLoadYAMLConfigFile(t *testing.T) {
+	configDir := t.TempDir()
+	yamlFile := filepath.Join(configDir, "config.yaml")
+	content := "# the same names as in JSON\nport: \"9090\"\nhost: file-host\nworkers: 4\ntimeout: 1m30s\n"
+	if err := ioutil.WriteFile(yamlFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var config testConfig
+	if err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", yamlFile}, &config, testSettings); err != nil {
+		t.Fatal(err)
+	}
+	expected := testConfig{Port: "9090", Host: "file-host", Workers: 4, Timeout: Duration(90 * time.Second)}
+	if config != expected {
+		t.Fatalf("want %+v, got %+v\n", expected, config)
+	}
+
+	unknownFile := filepath.Join(configDir, "unknown.yml")
+	if err := ioutil.WriteFile(unknownFile, []byte("hostname: file-host\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", unknownFile}, &config, testSettings)
+	if err == nil || !strings.Contains(err.Error(), "hostname") {
+		t.Fatalf("want the unknown field rejected, got %v\n", err)
+	}
+}
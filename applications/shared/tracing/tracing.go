@@ -0,0 +1,155 @@
+// Package tracing sets up OpenTelemetry for the applications: it configures the trace exporter from the environment and
+// propagates the trace between services in the W3C traceparent, in HTTP headers as well as in message headers.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ENV_KEY_SERVICE_NAME    = "OTEL_SERVICE_NAME"
+	ENV_KEY_TRACES_EXPORTER = "OTEL_TRACES_EXPORTER"
+	ENV_KEY_OTLP_ENDPOINT   = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	ENV_KEY_TRACES_FILE     = "TRACES_FILE"
+
+	NONE_TRACES_EXPORTER    = "none"
+	OTLP_TRACES_EXPORTER    = "otlp"
+	CONSOLE_TRACES_EXPORTER = "console"
+	FILE_TRACES_EXPORTER    = "file"
+
+	TRACEPARENT_HEADER = "traceparent"
+	INSTRUMENTATION    = "go-on-oci"
+)
+
+// propagator carries the span context between services as W3C traceparent and tracestate
+var propagator = propagation.TraceContext{}
+
+// provider is the tracer provider that Configure installed, to flush and release at Shutdown
+var provider *sdktrace.TracerProvider
+
+// Configure installs the tracer provider of the application, set up from OTEL_SERVICE_NAME (defaulting to serviceName) and
+// OTEL_TRACES_EXPORTER: none (the default), otlp (to OTEL_EXPORTER_OTLP_ENDPOINT), console (to stdout) or file (to TRACES_FILE).
+// Without an exporter, spans are not recorded but the trace is still propagated.
+func Configure(serviceName string) error {
+	if name := os.Getenv(ENV_KEY_SERVICE_NAME); name != "" {
+		serviceName = name
+	}
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	}
+	switch exporterName := strings.ToLower(os.Getenv(ENV_KEY_TRACES_EXPORTER)); exporterName {
+	case "", NONE_TRACES_EXPORTER:
+		// a caller that sampled the trace keeps it sampled in the traceparent passed on, for the services after this one
+		options = append(options, sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.NeverSample())))
+	case OTLP_TRACES_EXPORTER:
+		var otlpOptions []otlptracehttp.Option
+		if os.Getenv(ENV_KEY_OTLP_ENDPOINT) == "" {
+			// a collector next to the application, at http://localhost:4318
+			otlpOptions = append(otlpOptions, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(context.Background(), otlpOptions...)
+		if err != nil {
+			return fmt.Errorf("creating the OTLP traces exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	case CONSOLE_TRACES_EXPORTER, "stdout":
+		exporter, err := writerExporter(os.Stdout)
+		if err != nil {
+			return err
+		}
+		options = append(options, sdktrace.WithSyncer(exporter))
+	case FILE_TRACES_EXPORTER:
+		fileName := os.Getenv(ENV_KEY_TRACES_FILE)
+		if fileName == "" {
+			return fmt.Errorf("%s is required for the %s traces exporter", ENV_KEY_TRACES_FILE, FILE_TRACES_EXPORTER)
+		}
+		file, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("opening traces file: %w", err)
+		}
+		exporter, err := writerExporter(file)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		options = append(options, sdktrace.WithSyncer(&closingExporter{SpanExporter: exporter, file: file}))
+	default:
+		return fmt.Errorf("unknown traces exporter %q; use %s, %s, %s or %s", exporterName, NONE_TRACES_EXPORTER, OTLP_TRACES_EXPORTER, CONSOLE_TRACES_EXPORTER, FILE_TRACES_EXPORTER)
+	}
+	provider = sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	return nil
+}
+
+// Shutdown exports the spans that are still buffered and releases the exporter; call it when the application stops
+func Shutdown() error {
+	if provider == nil {
+		return nil
+	}
+	return provider.Shutdown(context.Background())
+}
+
+// writerExporter writes spans as JSON lines, to stdout or a file, for looking at traces without a collector
+func writerExporter(out io.Writer) (sdktrace.SpanExporter, error) {
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(out))
+	if err != nil {
+		return nil, fmt.Errorf("creating the traces exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// closingExporter closes the traces file once its exporter is shut down
+type closingExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+func (exporter *closingExporter) Shutdown(ctx context.Context) error {
+	err := exporter.SpanExporter.Shutdown(ctx)
+	if closeErr := exporter.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Start starts a span of the given kind as child of the span in ctx, or of the remote span context that Extract put in ctx;
+// otherwise the span starts a new trace. The returned context carries the new span.
+func Start(ctx context.Context, name string, kind trace.SpanKind, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(INSTRUMENTATION).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attributes...))
+}
+
+// RecordError marks the span as failed with the error; a nil error is ignored
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Extract returns a context with the span context that another service put in carrier, such as
+// propagation.HeaderCarrier for HTTP headers or propagation.MapCarrier for message headers; the next span started from
+// the context continues that trace
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return propagator.Extract(ctx, carrier)
+}
+
+// Inject puts the span context of the span in ctx in carrier, for the service that receives the request or message
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	propagator.Inject(ctx, carrier)
+}
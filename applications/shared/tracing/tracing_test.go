@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestPropagation(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	defaultProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(defaultProvider)
+
+	incoming := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := Extract(context.Background(), propagation.MapCarrier{TRACEPARENT_HEADER: incoming})
+	ctx, span := Start(ctx, "processPersonMessage", trace.SpanKindConsumer)
+	outgoing := propagation.MapCarrier{}
+	Inject(ctx, outgoing)
+	RecordError(span, nil)
+	RecordError(span, errors.New("ORA-00054: resource busy"))
+	span.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got %d\n", len(spans))
+	}
+	consumer := spans[0]
+	if consumer.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || consumer.Parent.SpanID().String() != "00f067aa0ba902b7" || consumer.SpanKind != trace.SpanKindConsumer {
+		t.Fatalf("want a consumer span in the trace of the producer, got %+v\n", consumer)
+	}
+	if consumer.Status.Code != codes.Error || len(consumer.Events) != 1 {
+		t.Fatalf("want the span failed with one error event, got %+v and %d events\n", consumer.Status, len(consumer.Events))
+	}
+	expected := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + consumer.SpanContext.SpanID().String() + "-01"
+	if outgoing[TRACEPARENT_HEADER] != expected {
+		t.Fatalf("want traceparent %s, got %s\n", expected, outgoing[TRACEPARENT_HEADER])
+	}
+	for _, invalid := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if trace.SpanContextFromContext(Extract(context.Background(), propagation.MapCarrier{TRACEPARENT_HEADER: invalid})).IsValid() {
+			t.Fatalf("want %q to be rejected\n", invalid)
+		}
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	defaultProvider := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(defaultProvider)
+	t.Setenv(ENV_KEY_TRACES_EXPORTER, FILE_TRACES_EXPORTER)
+	t.Setenv(ENV_KEY_TRACES_FILE, "")
+	if err := Configure("test"); err == nil {
+		t.Fatalf("want an error for the file exporter without %s\n", ENV_KEY_TRACES_FILE)
+	}
+	t.Setenv(ENV_KEY_TRACES_EXPORTER, "zipkin")
+	if err := Configure("test"); err == nil {
+		t.Fatalf("want an error for an unknown exporter\n")
+	}
+
+	t.Setenv(ENV_KEY_TRACES_EXPORTER, NONE_TRACES_EXPORTER)
+	if err := Configure("test"); err != nil {
+		t.Fatalf("configuring without an exporter: %s\n", err)
+	}
+	defer Shutdown()
+	for _, flags := range []string{"00", "01"} {
+		ctx := Extract(context.Background(), propagation.MapCarrier{TRACEPARENT_HEADER: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-" + flags})
+		ctx, span := Start(ctx, "unexported", trace.SpanKindServer)
+		outgoing := propagation.MapCarrier{}
+		Inject(ctx, outgoing)
+		span.End()
+		// the caller decides the sampling, so that the services after this one export the trace it sampled
+		expected := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + span.SpanContext().SpanID().String() + "-" + flags
+		if outgoing[TRACEPARENT_HEADER] != expected {
+			t.Fatalf("want traceparent %s, got %s\n", expected, outgoing[TRACEPARENT_HEADER])
+		}
+	}
+	if _, span := Start(context.Background(), "unsampled", trace.SpanKindServer); span.SpanContext().IsSampled() {
+		t.Fatalf("want a new trace unsampled without an exporter\n")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	TEXT_CONTENT_TYPE = "text/plain"
+	JSON_CONTENT_TYPE = "application/json"
+	HTML_CONTENT_TYPE = "text/html"
+)
+
+// NegotiateContentType picks the offered media type that the Accept header prefers, the first offer on a tie and when the
+// header is empty; it returns "" when the header rules out all offers
+func NegotiateContentType(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	type mediaRange struct {
+		mediaType string
+		quality   float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		parameters := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(parameters[0]))
+		quality := 1.0
+		for _, parameter := range parameters[1:] {
+			if value := strings.TrimSpace(parameter); strings.HasPrefix(value, "q=") {
+				quality, _ = strconv.ParseFloat(value[2:], 64)
+			}
+		}
+		if mediaType != "" {
+			ranges = append(ranges, mediaRange{mediaType, quality})
+		}
+	}
+	best, bestQuality := "", 0.0
+	for _, offer := range offers {
+		// the most specific range that matches the offer determines its quality: text/html over text/* over */*
+		quality, specificity := 0.0, -1
+		for _, r := range ranges {
+			rangeSpecificity := -1
+			switch {
+			case r.mediaType == offer:
+				rangeSpecificity = 2
+			case strings.HasSuffix(r.mediaType, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(r.mediaType, "*")):
+				rangeSpecificity = 1
+			case r.mediaType == "*/*":
+				rangeSpecificity = 0
+			}
+			if rangeSpecificity > specificity {
+				quality, specificity = r.quality, rangeSpecificity
+			}
+		}
+		if quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+	return best
+}
@@ -5,20 +5,36 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 
 	fdk "github.com/fnproject/fdk-go"
+	"go-on-oci-shared/logging"
 )
 
+const REQUEST_ID_HEADER = "X-Request-Id"
+
 func main() {
+	if err := logging.Configure(); err != nil {
+		logging.Error("invalid logging configuration", "error", err)
+	}
 	fdk.Handle(fdk.HandlerFunc(myHandler))
 }
 
+// invocationRequestID returns the X-Request-Id the caller sent along with the invocation or, without one, the call ID of Fn
+func invocationRequestID(fnctx fdk.Context) string {
+	if requestID := fnctx.Header().Get(REQUEST_ID_HEADER); requestID != "" {
+		return requestID
+	}
+	return fnctx.CallID()
+}
+
 type Person struct {
 	Name string `json:"name"`
 }
 
 func myHandler(ctx context.Context, in io.Reader, out io.Writer) {
+	requestID := invocationRequestID(fdk.GetContext(ctx))
+	ctx = logging.ContextWithRequestID(ctx, requestID)
+	fdk.SetHeader(out, REQUEST_ID_HEADER, requestID)
 	p := &Person{Name: "World"}
 	message := ""
 	err := json.NewDecoder(in).Decode(p)
@@ -32,11 +48,11 @@ func myHandler(ctx context.Context, in io.Reader, out io.Writer) {
 	}{
 		Msg: message,
 	}
-	log.Print("Inside Go Greeter function new version")
+	logging.FromContext(ctx).Info("inside Go Greeter function new version", "name", p.Name)
 
 	err = json.NewEncoder(out).Encode(&msg)
 	if err != nil {
-		log.Print("Error occurred in function greeter ", err)
+		logging.FromContext(ctx).Error("error occurred in function greeter", "error", err)
 	}
 
 }
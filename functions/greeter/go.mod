@@ -1,7 +1,9 @@
 module oci-client
 
-go 1.16
+go 1.25.0
 
-require (
-	github.com/fnproject/fdk-go v0.0.17
-)
+require github.com/fnproject/fdk-go v0.0.17
+
+require go-on-oci-shared v0.0.0
+
+replace go-on-oci-shared => ../../applications/shared
@@ -5,20 +5,36 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/url"
 
 	fdk "github.com/fnproject/fdk-go"
+	"go-on-oci-shared/logging"
 )
 
+const REQUEST_ID_HEADER = "X-Request-Id"
+
 func main() {
+	if err := logging.Configure(); err != nil {
+		logging.Error("invalid logging configuration", "error", err)
+	}
 	fdk.Handle(fdk.HandlerFunc(myHandler))
 }
 
+// invocationRequestID returns the X-Request-Id the caller sent along with the invocation or, without one, the call ID of Fn
+func invocationRequestID(fnctx fdk.Context) string {
+	if requestID := fnctx.Header().Get(REQUEST_ID_HEADER); requestID != "" {
+		return requestID
+	}
+	return fnctx.CallID()
+}
+
 func myHandler(ctx context.Context, in io.Reader, out io.Writer) {
 	objectName := "defaultObjectName.txt"
 	bucketName := "the-bucket"
-	fnctx := fdk.GetContext(ctx)             // fnctx contains relevant elements about the Function itself
+	fnctx := fdk.GetContext(ctx) // fnctx contains relevant elements about the Function itself
+	requestID := invocationRequestID(fnctx)
+	ctx = logging.ContextWithRequestID(ctx, requestID)
+	fdk.SetHeader(out, REQUEST_ID_HEADER, requestID)
 	fnhttpctx, ok := fnctx.(fdk.HTTPContext) // fnhttpctx contains relevant elements about the HTTP Request that triggered it
 	if ok {                                  // an HTTPContent was found which means that this was an HTTP request (not an fn invoke) that triggered the function
 		u, err := url.Parse(fnhttpctx.RequestURL())
@@ -36,7 +52,7 @@ func myHandler(ctx context.Context, in io.Reader, out io.Writer) {
 	}
 	var message string
 	if compartmentOCID, ok := fnctx.Config()["compartmentOCID"]; ok { // assuming an Application or Function Configuration Parameter called compartmentOCID has been defined
-		msg, err := CreateObject(objectName, bucketName, compartmentOCID)
+		msg, err := CreateObject(ctx, objectName, bucketName, compartmentOCID)
 		if err != nil {
 			message = fmt.Sprintf("Error in function execution: %s", err)
 		} else {
@@ -54,7 +70,7 @@ func myHandler(ctx context.Context, in io.Reader, out io.Writer) {
 	}
 	err := json.NewEncoder(out).Encode(&response)
 	if err != nil {
-		log.Print("Error occurred in function during response encoding ", err)
+		logging.FromContext(ctx).Error("error occurred in function during response encoding", "error", err)
 	}
 
 }
@@ -1,9 +1,14 @@
 module oci-client
 
-go 1.16
+go 1.25.0
 
 require (
 	github.com/fnproject/fdk-go v0.0.17
 	github.com/oracle/oci-go-sdk/v54 v54.0.0
-
 )
+
+require go-on-oci-shared v0.0.0
+
+require github.com/sony/gobreaker v0.4.2-0.20210216022020-dd874f9dd33b // indirect
+
+replace go-on-oci-shared => ../../applications/shared
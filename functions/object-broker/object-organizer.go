@@ -6,34 +6,35 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 
 	"github.com/oracle/oci-go-sdk/v54/common/auth"
 	"github.com/oracle/oci-go-sdk/v54/objectstorage"
+	"go-on-oci-shared/logging"
 )
 
-func CreateObject(objectName string, bucketName string, compartmentOCID string) (string, error) {
+// CreateObject writes an object to the bucket and reads it back; the log records carry the request ID in ctx
+func CreateObject(ctx context.Context, objectName string, bucketName string, compartmentOCID string) (string, error) {
+	objectLogger := logging.FromContext(ctx).With("object", objectName, "bucket", bucketName)
 	configurationProvider, err := auth.ResourcePrincipalConfigurationProvider()
 	if err != nil {
-		log.Printf("failed to get oci configurationprovider based on resource principal authentication : %s", err)
+		objectLogger.Error("failed to get oci configurationprovider based on resource principal authentication", "error", err)
 		return "", err
 	}
 	objectStorageClient, cerr := objectstorage.NewObjectStorageClientWithConfigurationProvider(configurationProvider)
 	if cerr != nil {
-		log.Printf("failed to create ObjectStorageClient : %s", cerr)
+		objectLogger.Error("failed to create ObjectStorageClient", "error", cerr)
 		return "", err
 	}
-	ctx := context.Background()
 	namespace, cerr := getNamespace(ctx, objectStorageClient)
 	if cerr != nil {
-		log.Printf("failed to get namespace : %s", cerr)
+		objectLogger.Error("failed to get namespace", "error", cerr)
 	} else {
-		log.Printf("Namespace : %s", namespace)
+		objectLogger.Debug("retrieved namespace", "namespace", namespace)
 	}
 
 	err = ensureBucketExists(ctx, objectStorageClient, namespace, bucketName, compartmentOCID)
 	if err != nil {
-		log.Printf("failed to read or create bucket : %s", err)
+		objectLogger.Error("failed to read or create bucket", "error", err)
 		return "", err
 	}
 
@@ -41,17 +42,17 @@ func CreateObject(objectName string, bucketName string, compartmentOCID string)
 	objectLength := int64(len(contentToWrite))
 	err = putObject(ctx, objectStorageClient, namespace, bucketName, objectName, objectLength, ioutil.NopCloser(bytes.NewReader(contentToWrite)))
 	if err != nil {
-		log.Printf("failed to write object to OCI Object storage : %s", err)
+		objectLogger.Error("failed to write object to OCI Object storage", "error", err)
 		return "", err
 	}
 
 	var contentRead []byte
 	contentRead, err = getObject(ctx, objectStorageClient, namespace, bucketName, objectName)
 	if err != nil {
-		log.Printf("failed to get object %s from OCI Object storage : %s", objectName, err)
+		objectLogger.Error("failed to get object from OCI Object storage", "error", err)
 		return "", err
 	}
-	log.Printf("Object read from OCI Object Storage contains this content: %s", contentRead)
+	objectLogger.Info("object read from OCI Object Storage", "content", string(contentRead))
 	return fmt.Sprintf("Object %s written to bucket %s and then read back from OCI Object Storage with this content: %s", objectName, bucketName, contentRead), nil
 }
 
@@ -79,7 +80,7 @@ func ensureBucketExists(ctx context.Context, client objectstorage.ObjectStorageC
 		}
 		return err
 	}
-	fmt.Printf("bucket %s already exists", name)
+	logging.FromContext(ctx).Debug("bucket already exists", "bucket", name)
 	return nil
 }
 
@@ -96,7 +97,7 @@ func createBucket(ctx context.Context, client objectstorage.ObjectStorageClient,
 	if err != nil {
 		return fmt.Errorf("failed to create bucket on OCI : %w", err)
 	} else {
-		fmt.Printf("created bucket : %s", bucketName)
+		logging.FromContext(ctx).Info("created bucket", "bucket", bucketName)
 	}
 	return nil
 }
@@ -110,7 +111,7 @@ func putObject(ctx context.Context, client objectstorage.ObjectStorageClient, na
 		PutObjectBody: content,
 	}
 	_, err := client.PutObject(ctx, request)
-	fmt.Printf("Put object %s in bucket %s", objectname, bucketName)
+	logging.FromContext(ctx).Info("put object in bucket", "object", objectname, "bucket", bucketName)
 	if err != nil {
 		return fmt.Errorf("failed to put object on OCI : %w", err)
 	}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_LANGUAGE = "en"
+	NAME_PLACEHOLDER = "{name}"
+)
+
+// greetingCatalogFiles are the message catalogs, one per language, named after the language tag (such as nl.json or pt-BR.json)
+//
+//go:embed greetings/*.json
+var greetingCatalogFiles embed.FS
+
+// greetingCatalog holds the greeting templates of a language; the templates contain {name} where the name goes.
+// Morning, Afternoon and Evening are optional and fall back to Hello.
+type greetingCatalog struct {
+	Language  string `json:"-"`
+	Stranger  string `json:"stranger"`
+	Hello     string `json:"hello"`
+	Morning   string `json:"morning"`
+	Afternoon string `json:"afternoon"`
+	Evening   string `json:"evening"`
+}
+
+// greetingCatalogs maps lower case language tags to their catalogs; it must contain DEFAULT_LANGUAGE
+var greetingCatalogs = mustLoadGreetingCatalogs()
+
+// loadGreetingCatalogs reads the embedded message catalogs
+func loadGreetingCatalogs() (map[string]greetingCatalog, error) {
+	files, err := greetingCatalogFiles.ReadDir("greetings")
+	if err != nil {
+		return nil, err
+	}
+	catalogs := map[string]greetingCatalog{}
+	for _, file := range files {
+		content, err := greetingCatalogFiles.ReadFile(path.Join("greetings", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var catalog greetingCatalog
+		if err := json.Unmarshal(content, &catalog); err != nil {
+			return nil, fmt.Errorf("greeting catalog %s: %w", file.Name(), err)
+		}
+		if catalog.Stranger == "" || !strings.Contains(catalog.Hello, NAME_PLACEHOLDER) {
+			return nil, fmt.Errorf("greeting catalog %s needs a stranger and a hello with %s", file.Name(), NAME_PLACEHOLDER)
+		}
+		catalog.Language = strings.TrimSuffix(file.Name(), ".json")
+		catalogs[strings.ToLower(catalog.Language)] = catalog
+	}
+	if _, ok := catalogs[DEFAULT_LANGUAGE]; !ok {
+		return nil, fmt.Errorf("there is no greeting catalog for the default language %s", DEFAULT_LANGUAGE)
+	}
+	return catalogs, nil
+}
+
+func mustLoadGreetingCatalogs() map[string]greetingCatalog {
+	catalogs, err := loadGreetingCatalogs()
+	if err != nil {
+		panic(err)
+	}
+	return catalogs
+}
+
+// template returns the greeting for the part of the day of localTime, or the plain greeting without a local time
+func (catalog greetingCatalog) template(localTime *time.Time) string {
+	if localTime == nil {
+		return catalog.Hello
+	}
+	var template string
+	switch hour := localTime.Hour(); {
+	case hour >= 5 && hour < 12:
+		template = catalog.Morning
+	case hour >= 12 && hour < 18:
+		template = catalog.Afternoon
+	case hour >= 18 && hour < 23:
+		template = catalog.Evening
+	}
+	if template == "" {
+		return catalog.Hello
+	}
+	return template
+}
+
+// matchLanguage picks the catalog for the first of the language tags that there is one for: the tag itself (nl-BE),
+// its base language (nl) or another region of that language (pt-BR for pt); DEFAULT_LANGUAGE when there is none
+func matchLanguage(languages []string) string {
+	for _, language := range languages {
+		language = strings.ToLower(strings.TrimSpace(language))
+		if _, ok := greetingCatalogs[language]; ok {
+			return language
+		}
+		base := strings.SplitN(language, "-", 2)[0]
+		if _, ok := greetingCatalogs[base]; ok {
+			return base
+		}
+		var regional []string
+		for tag := range greetingCatalogs {
+			if strings.HasPrefix(tag, base+"-") {
+				regional = append(regional, tag)
+			}
+		}
+		if len(regional) > 0 {
+			sort.Strings(regional)
+			return regional[0]
+		}
+	}
+	return DEFAULT_LANGUAGE
+}
+
+// AcceptedLanguages returns the language tags of an Accept-Language header, most preferred first;
+// tags with q=0 and the wildcard are left out
+func AcceptedLanguages(acceptLanguage string) []string {
+	type weightedLanguage struct {
+		tag     string
+		quality float64
+	}
+	var weighted []weightedLanguage
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		parameters := strings.Split(part, ";")
+		tag := strings.TrimSpace(parameters[0])
+		quality := 1.0
+		for _, parameter := range parameters[1:] {
+			if value := strings.TrimSpace(parameter); strings.HasPrefix(value, "q=") {
+				quality, _ = strconv.ParseFloat(value[2:], 64)
+			}
+		}
+		if tag != "" && tag != "*" && quality > 0 {
+			weighted = append(weighted, weightedLanguage{tag, quality})
+		}
+	}
+	sort.SliceStable(weighted, func(i, j int) bool { return weighted[i].quality > weighted[j].quality })
+	languages := make([]string, len(weighted))
+	for i, language := range weighted {
+		languages[i] = language.tag
+	}
+	return languages
+}
@@ -1,66 +1,177 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
+	"html/template"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // time zones for the tz parameter of /greet, also where the container image has none
+
+	"go-on-oci-shared/httpserver"
+	"go-on-oci-shared/logging"
+	"go-on-oci-shared/metrics"
+	"go-on-oci-shared/tracing"
 )
 
 const (
 	DEFAULT_HTTP_SERVER_PORT = "8080"
 	ENV_KEY_HTTP_SERVER_PORT = "HTTP_SERVER_PORT"
 	ENV_KEY_MYSERVER_VERSION = "VERSION_OF_MYSERVER"
+
+	ENV_KEY_SHUTDOWN_GRACE_PERIOD = "SHUTDOWN_GRACE_PERIOD"
 )
 
 const (
 	GREET_PATH       = "/greet"
 	STATIC_SITE_PATH = "/site/"
-	ROOT_PATH        = "/"
 )
 
-func ComposeGreeting(name string) string {
-	nameToGreet := "Stranger"
+// ComposeGreeting greets name, or a stranger, in the first of the languages that there is a catalog for, English otherwise.
+// With the local time of the one greeted, the greeting suits the part of the day. The language of the greeting is returned too.
+func ComposeGreeting(name string, languages []string, localTime *time.Time) (greeting string, language string) {
+	catalog := greetingCatalogs[matchLanguage(languages)]
+	nameToGreet := catalog.Stranger
 	if len(name) > 0 {
 		nameToGreet = name
-		log.Printf(" --  query parameter name is set to %s", name)
+		logging.Debug("query parameter name is set", "name", name)
 	}
-	return fmt.Sprintf("Hello %s!", nameToGreet)
+	return strings.ReplaceAll(catalog.template(localTime), NAME_PLACEHOLDER, nameToGreet), catalog.Language
+}
+
+// Greeting is the JSON envelope of a greeting
+type Greeting struct {
+	Greeting  string    `json:"greeting"`
+	Name      string    `json:"name"`
+	Language  string    `json:"language"`
+	Version   string    `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
-func greetHandler(response http.ResponseWriter, request *http.Request) {
-	log.Printf("Handle Request for method %s on path %s", request.Method, request.URL.Path)
-	if request.Method != "GET" {
-		http.Error(response, "Method is not supported unfortunately. ", http.StatusNotFound)
-		return
+var greetingPage = template.Must(template.New("greeting").Parse(`<!DOCTYPE html>
+<html lang="{{.Language}}">
+<head><meta charset="utf-8"><title>{{.Greeting}}</title></head>
+<body><h1>{{.Greeting}}</h1><p>my-server {{.Version}} at <time datetime="{{.Timestamp.Format "2006-01-02T15:04:05Z07:00"}}">{{.Timestamp.Format "2006-01-02 15:04:05 MST"}}</time></p></body>
+</html>
+`))
+
+// GreetHandler greets in the language of the lang query parameter or else the Accept-Language header; with the time zone
+// of the caller in the tz query parameter (such as Europe/Amsterdam), it greets for the time of day there. The greeting is
+// plain text, JSON or HTML, whichever the Accept header prefers; the JSON and HTML include the version of the server.
+func GreetHandler(version string) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		response.Header().Add("Vary", "Accept, Accept-Language")
+		contentType := NegotiateContentType(request.Header.Get("Accept"), TEXT_CONTENT_TYPE, JSON_CONTENT_TYPE, HTML_CONTENT_TYPE)
+		if contentType == "" {
+			http.Error(response, fmt.Sprintf("406 greetings are available as %s, %s and %s", TEXT_CONTENT_TYPE, JSON_CONTENT_TYPE, HTML_CONTENT_TYPE), http.StatusNotAcceptable)
+			return
+		}
+		query := request.URL.Query()
+		languages := AcceptedLanguages(request.Header.Get("Accept-Language"))
+		if lang := query.Get("lang"); lang != "" {
+			languages = append([]string{lang}, languages...)
+		}
+		var localTime *time.Time
+		if timeZone := query.Get("tz"); timeZone != "" {
+			location, err := time.LoadLocation(timeZone)
+			if err != nil {
+				http.Error(response, fmt.Sprintf("Unknown time zone %s", timeZone), http.StatusBadRequest)
+				return
+			}
+			now := time.Now().In(location)
+			localTime = &now
+		}
+		text, language := ComposeGreeting(query.Get("name"), languages, localTime)
+		greeting := Greeting{Greeting: text, Name: query.Get("name"), Language: language, Version: version, Timestamp: time.Now().UTC()}
+		response.Header().Set("Content-Language", language)
+		response.Header().Set("Content-Type", contentType+"; charset=utf-8")
+		var err error
+		switch contentType {
+		case JSON_CONTENT_TYPE:
+			err = json.NewEncoder(response).Encode(greeting)
+		case HTML_CONTENT_TYPE:
+			err = greetingPage.Execute(response, greeting)
+		default:
+			_, err = fmt.Fprint(response, greeting.Greeting)
+		}
+		if err != nil {
+			logging.FromContext(request.Context()).Error("failed to write greeting", "contentType", contentType, "error", err)
+		}
 	}
-	queryName := request.URL.Query().Get("name")
-	fmt.Fprint(response, ComposeGreeting(queryName))
 }
 
 func fallbackHandler(response http.ResponseWriter, request *http.Request) {
-	log.Printf("Warning: Request for unhandled method %s on path %s", request.Method, request.URL.Path)
-	http.Error(response, "404 path/method combination not currently supported. Try /greet or /site", http.StatusNotFound)
+	logging.FromContext(request.Context()).Warn("request for unhandled path", "method", request.Method, "path", request.URL.Path)
+	http.Error(response, "404 path not currently supported. Try /greet or /site", http.StatusNotFound)
+}
+
+// middlewareConfigFromEnvironment reads the settings of the optional middlewares from the environment
+func middlewareConfigFromEnvironment() (httpserver.MiddlewareConfig, error) {
+	config := httpserver.MiddlewareConfig{
+		CORSAllowedOrigins: os.Getenv(httpserver.ENV_KEY_CORS_ALLOWED_ORIGINS),
+		APITokens:          os.Getenv(httpserver.ENV_KEY_API_TOKENS),
+		PublicPaths:        []string{metrics.METRICS_PATH},
+	}
+	for envKey, setting := range map[string]*int{httpserver.ENV_KEY_RATE_LIMIT: &config.RateLimit, httpserver.ENV_KEY_RATE_LIMIT_BURST: &config.RateLimitBurst, httpserver.ENV_KEY_TRUSTED_PROXIES: &config.TrustedProxies} {
+		if value, ok := os.LookupEnv(envKey); ok {
+			number, err := strconv.Atoi(value)
+			if err != nil || number < 0 {
+				return config, fmt.Errorf("environment variable %s is not a non-negative number: %q", envKey, value)
+			}
+			*setting = number
+		}
+	}
+	return config, nil
 }
 
 func main() {
+	if err := logging.Configure(); err != nil {
+		logging.Error("invalid logging configuration", "error", err)
+		os.Exit(1)
+	}
+	if err := tracing.Configure("my-server"); err != nil {
+		logging.Error("invalid tracing configuration", "error", err)
+		os.Exit(1)
+	}
 	httpServerPort, ok := os.LookupEnv(ENV_KEY_HTTP_SERVER_PORT)
 	if !ok {
 		httpServerPort = DEFAULT_HTTP_SERVER_PORT
-		log.Printf("Environment Variable %s not set; using default value: %s", ENV_KEY_HTTP_SERVER_PORT, DEFAULT_HTTP_SERVER_PORT)
+		logging.Info("environment variable not set; using default value", "variable", ENV_KEY_HTTP_SERVER_PORT, "value", DEFAULT_HTTP_SERVER_PORT)
 	}
 	myserverVersion, ok := os.LookupEnv(ENV_KEY_MYSERVER_VERSION)
 	if !ok {
 		myserverVersion = "unknown"
-		log.Printf("Environment Variable %s not set", ENV_KEY_MYSERVER_VERSION)
+		logging.Info("environment variable not set", "variable", ENV_KEY_MYSERVER_VERSION)
+	}
+	gracePeriod := httpserver.DEFAULT_SHUTDOWN_GRACE_PERIOD
+	if value, ok := os.LookupEnv(ENV_KEY_SHUTDOWN_GRACE_PERIOD); ok {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			logging.Error("environment variable is not a valid duration", "variable", ENV_KEY_SHUTDOWN_GRACE_PERIOD, "value", value)
+			os.Exit(1)
+		}
+		gracePeriod = duration
+	}
+	middlewareConfig, err := middlewareConfigFromEnvironment()
+	if err != nil {
+		logging.Error("invalid middleware configuration", "error", err)
+		os.Exit(1)
 	}
+	router := httpserver.NewRouter()
 	fileServer := http.FileServer(http.Dir("./website"))
-	http.Handle(STATIC_SITE_PATH, http.StripPrefix("/site/", fileServer))
-	http.HandleFunc(GREET_PATH, greetHandler)
-	http.HandleFunc(ROOT_PATH, fallbackHandler)
+	router.Handle(STATIC_SITE_PATH, http.StripPrefix("/site/", fileServer), http.MethodGet)
+	router.HandleFunc(GREET_PATH, GreetHandler(myserverVersion), http.MethodGet)
+	router.HandleFunc(metrics.METRICS_PATH, metrics.Handler(metrics.Default()), http.MethodGet)
+	router.NotFound = http.HandlerFunc(fallbackHandler)
 
-	log.Printf("Starting my-server (version %s) listening for requests at port %s\n", myserverVersion, httpServerPort)
-	if err := http.ListenAndServe(":"+httpServerPort, nil); err != nil {
-		log.Fatal(err)
+	logging.Info("starting my-server", "version", myserverVersion, "port", httpServerPort)
+	handler := httpserver.ServerHandler(router, middlewareConfig)
+	if err := httpserver.RunServer(httpserver.NewServer(httpServerPort, handler), gracePeriod, tracing.Shutdown); err != nil {
+		logging.Error("serious problem and signing off", "error", err)
+		os.Exit(1)
 	}
+	logging.Info("my-server stopped")
 }
@@ -1,16 +1,133 @@
 package main
 
-import "testing"
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestComposeGreeting(t *testing.T) {
-	cases := []struct{ name, expected string }{
-		{"", "Hello Stranger!"}, {"Mary", "Hello Mary!"},
+	at := func(hour int) *time.Time {
+		localTime := time.Date(2022, 3, 14, hour, 30, 0, 0, time.UTC)
+		return &localTime
+	}
+	cases := []struct {
+		name      string
+		languages []string
+		localTime *time.Time
+		expected  string
+		language  string
+	}{
+		{"", nil, nil, "Hello Stranger!", "en"},
+		{"Mary", nil, nil, "Hello Mary!", "en"},
+		{"Mary", []string{"nl"}, nil, "Hallo Mary!", "nl"},
+		{"", []string{"nl-BE"}, nil, "Hallo Vreemdeling!", "nl"},
+		{"Maria", []string{"pt"}, nil, "Olá Maria!", "pt-BR"},
+		{"Marie", []string{"sv", "fr-CA", "de"}, nil, "Bonjour Marie !", "fr"},
+		{"Mary", []string{"sv"}, nil, "Hello Mary!", "en"},
+		{"Mary", nil, at(7), "Good morning Mary!", "en"},
+		{"Mary", nil, at(14), "Good afternoon Mary!", "en"},
+		{"Mary", nil, at(20), "Good evening Mary!", "en"},
+		{"Mary", nil, at(2), "Hello Mary!", "en"},
+		{"María", []string{"es"}, at(21), "¡Buenas noches María!", "es"},
+		{"", []string{"de"}, at(9), "Guten Morgen Fremder!", "de"},
 	}
 
 	for _, c := range cases {
-		result := ComposeGreeting(c.name)
+		result, language := ComposeGreeting(c.name, c.languages, c.localTime)
+		if result != c.expected || language != c.language {
+			t.Fatalf("want %s in %s, got %s in %s\n", c.expected, c.language, result, language)
+		}
+	}
+}
+
+func TestAcceptedLanguages(t *testing.T) {
+	cases := []struct{ acceptLanguage, expected string }{
+		{"", "[]"},
+		{"nl-BE, nl;q=0.9, en;q=0.8", "[nl-BE nl en]"},
+		{"en;q=0.5, de, *;q=0.1, fr;q=0", "[de en]"},
+	}
+
+	for _, c := range cases {
+		result := fmt.Sprint(AcceptedLanguages(c.acceptLanguage))
 		if result != c.expected {
-			t.Fatalf("want %s, got %s\n", c.expected, result)
+			t.Fatalf("%q: want %s, got %s\n", c.acceptLanguage, c.expected, result)
 		}
 	}
 }
+
+func TestGreetHandlerLanguage(t *testing.T) {
+	cases := []struct{ target, acceptLanguage, expected, language string }{
+		{"/greet?name=Mary", "de-AT, en;q=0.5", "Hallo Mary!", "de"},
+		{"/greet?name=Mary&lang=es", "de-AT, en;q=0.5", "¡Hola Mary!", "es"},
+		{"/greet?name=Mary&lang=xx", "nl", "Hallo Mary!", "nl"},
+	}
+
+	for _, c := range cases {
+		request := httptest.NewRequest("GET", c.target, nil)
+		request.Header.Set("Accept-Language", c.acceptLanguage)
+		response := httptest.NewRecorder()
+		GreetHandler("test")(response, request)
+		if response.Body.String() != c.expected || response.Header().Get("Content-Language") != c.language {
+			t.Fatalf("%s: want %s in %s, got %s in %s\n", c.target, c.expected, c.language, response.Body.String(), response.Header().Get("Content-Language"))
+		}
+	}
+	response := httptest.NewRecorder()
+	GreetHandler("test")(response, httptest.NewRequest("GET", "/greet?tz=Mars/Olympus_Mons", nil))
+	if response.Code != http.StatusBadRequest {
+		t.Fatalf("want 400 for an unknown time zone, got %d\n", response.Code)
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{TEXT_CONTENT_TYPE, JSON_CONTENT_TYPE, HTML_CONTENT_TYPE}
+	cases := []struct{ accept, expected string }{
+		{"", TEXT_CONTENT_TYPE},
+		{"*/*", TEXT_CONTENT_TYPE},
+		{"application/json", JSON_CONTENT_TYPE},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", HTML_CONTENT_TYPE},
+		{"text/*;q=0.5, application/json;q=0.4", TEXT_CONTENT_TYPE},
+		{"text/*, text/plain;q=0.1", HTML_CONTENT_TYPE},
+		{"*/*;q=0.1, application/json;q=0", TEXT_CONTENT_TYPE},
+		{"image/png", ""},
+	}
+
+	for _, c := range cases {
+		result := NegotiateContentType(c.accept, offers...)
+		if result != c.expected {
+			t.Fatalf("%q: want %q, got %q\n", c.accept, c.expected, result)
+		}
+	}
+}
+
+func TestGreetHandlerContentTypes(t *testing.T) {
+	cases := []struct{ accept, contentType, body string }{
+		{"", "text/plain; charset=utf-8", "Hello <b>Mary</b>!"},
+		{"application/json", "application/json; charset=utf-8", `"greeting":"Hello \u003cb\u003eMary\u003c/b\u003e!","name":"\u003cb\u003eMary\u003c/b\u003e","language":"en","version":"1.2.3"`},
+		{"text/html", "text/html; charset=utf-8", "<h1>Hello &lt;b&gt;Mary&lt;/b&gt;!</h1>"},
+		{"image/png", "text/plain; charset=utf-8", "406"},
+	}
+
+	for _, c := range cases {
+		request := httptest.NewRequest("GET", "/greet?name=%3Cb%3EMary%3C/b%3E", nil)
+		request.Header.Set("Accept", c.accept)
+		response := httptest.NewRecorder()
+		GreetHandler("1.2.3")(response, request)
+		if response.Header().Get("Content-Type") != c.contentType || !strings.Contains(response.Body.String(), c.body) {
+			t.Fatalf("%q: want %s containing %s, got %s: %s\n", c.accept, c.contentType, c.body, response.Header().Get("Content-Type"), response.Body.String())
+		}
+	}
+
+	request := httptest.NewRequest("GET", "/greet", nil)
+	request.Header.Set("Accept", "application/json")
+	response := httptest.NewRecorder()
+	GreetHandler("1.2.3")(response, request)
+	var greeting Greeting
+	if err := json.Unmarshal(response.Body.Bytes(), &greeting); err != nil || greeting.Greeting != "Hello Stranger!" || time.Since(greeting.Timestamp) > time.Minute {
+		t.Fatalf("unexpected envelope %s (%v)\n", response.Body.String(), err)
+	}
+}